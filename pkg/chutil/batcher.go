@@ -2,12 +2,10 @@ package chutil
 
 import (
 	"context"
-	"sync/atomic"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/pkg/errors"
-	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
 )
 
@@ -25,16 +23,12 @@ type Conn interface {
 // Run is a long-lived loop, so it must not be wrapped in runutil.Repeat.
 //
 // Any struct with `ch:"..."` tags works as T, because the rows are sent via
-// driver.Batch.AppendStruct.
+// driver.Batch.AppendStruct. The buffering is done by runutil.Batcher, named
+// "clickhouse" in its log messages and metrics.
 type Batcher[T any] struct {
-	conn        Conn
-	insertSQL   string
-	maxSize     int
-	maxWait     time.Duration
-	sendTimeout time.Duration
-
-	rows    chan T
-	dropped atomic.Uint64
+	conn      Conn
+	insertSQL string
+	batcher   *runutil.Batcher[T]
 }
 
 // NewWithConn creates a Batcher from an already-opened connection. Prefer New,
@@ -47,16 +41,18 @@ func NewWithConn[T any](conn Conn, insertSQL string, maxSize int, maxWait time.D
 
 // newBatcher is the shared constructor behind New and NewWithConn.
 func newBatcher[T any](conn Conn, insertSQL string, maxSize int, maxWait, sendTimeout time.Duration) *Batcher[T] {
-	return &Batcher[T]{
-		conn:        conn,
-		insertSQL:   insertSQL,
-		maxSize:     maxSize,
-		maxWait:     maxWait,
-		sendTimeout: sendTimeout,
-		// The buffer holds two batches so producers can keep enqueueing while a
-		// flush is in flight.
-		rows: make(chan T, maxSize*2),
+	b := &Batcher[T]{
+		conn:      conn,
+		insertSQL: insertSQL,
 	}
+
+	b.batcher = runutil.NewBatcher[T](
+		runutil.BatchSinkFunc[T](b.sendBatch), maxSize, maxWait,
+		runutil.WithBatcherName("clickhouse"),
+		runutil.WithFlushTimeout(sendTimeout),
+	)
+
+	return b
 }
 
 // Workers satisfies runutil.WorkerConfiger so the Batcher can be registered as a
@@ -71,17 +67,13 @@ func (b *Batcher[T]) Workers() []runutil.Worker {
 // dropped and counted, so producers (e.g. request handlers) are never slowed
 // down by a slow ClickHouse.
 func (b *Batcher[T]) Add(row T) {
-	select {
-	case b.rows <- row:
-	default:
-		b.dropped.Add(1)
-	}
+	_ = b.batcher.Add(context.Background(), row)
 }
 
 // Dropped returns the number of rows dropped so far because the buffer was
 // full.
 func (b *Batcher[T]) Dropped() uint64 {
-	return b.dropped.Load()
+	return b.batcher.Dropped()
 }
 
 // Run consumes the queue until the context is cancelled, batching rows to
@@ -90,61 +82,12 @@ func (b *Batcher[T]) Dropped() uint64 {
 // WARN rather than returned, so a single failed batch does not tear down
 // sibling workers or page on a self-recovering sink.
 func (b *Batcher[T]) Run(ctx context.Context) error {
-	ticker := time.NewTicker(b.maxWait)
-	defer ticker.Stop()
-
-	var buffer []T
-	flush := func() {
-		if len(buffer) == 0 {
-			return
-		}
-
-		err := b.sendBatch(ctx, buffer)
-		if err != nil {
-			logutil.Get(ctx).Warn("failed to send clickhouse batch", "error", err)
-		}
-
-		buffer = buffer[:0]
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			// Drain whatever is already queued so the final partial batch is
-			// not lost. The channel is intentionally not closed: Add may still
-			// be called and must not panic.
-			for {
-				select {
-				case row := <-b.rows:
-					buffer = append(buffer, row)
-					if len(buffer) >= b.maxSize {
-						flush()
-					}
-				default:
-					flush()
-					if dropped := b.dropped.Load(); dropped > 0 {
-						logutil.Get(ctx).Warn("dropped clickhouse rows due to full buffer", "count", dropped)
-					}
-					return nil
-				}
-			}
-		case row := <-b.rows:
-			buffer = append(buffer, row)
-			if len(buffer) >= b.maxSize {
-				flush()
-			}
-		case <-ticker.C:
-			flush()
-		}
-	}
+	return b.batcher.Run(ctx)
 }
 
+// sendBatch is the runutil.BatchSink of the Batcher. The timeout and the
+// detachment from the shutdown are handled by runutil.Batcher.
 func (b *Batcher[T]) sendBatch(ctx context.Context, rows []T) error {
-	// Detach from ctx cancellation so a flush triggered during shutdown still
-	// completes, while keeping a timeout as a backstop.
-	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), b.sendTimeout)
-	defer cancel()
-
 	batch, err := b.conn.PrepareBatch(ctx, b.insertSQL)
 	if err != nil {
 		return errors.Wrap(err, "prepare batch")
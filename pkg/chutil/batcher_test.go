@@ -137,7 +137,7 @@ func TestBatcherDrainOnCancel(t *testing.T) {
 	// Give Run a moment to consume the queued rows into its buffer, then cancel
 	// to trigger the drain-and-flush path.
 	require.Eventually(t, func() bool {
-		return len(b.rows) == 0
+		return b.batcher.Pending() == 0
 	}, time.Second, 5*time.Millisecond)
 	cancel()
 
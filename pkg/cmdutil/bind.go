@@ -0,0 +1,282 @@
+package cmdutil
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// flagAnnotationEnv contains the explicit environment variable of a flag,
+	// as defined by the env tag of BindStruct.
+	flagAnnotationEnv = "cmdutil_env"
+
+	// flagAnnotationRequired marks a flag that must be set by the command
+	// line, the environment or the config file.
+	flagAnnotationRequired = "cmdutil_required"
+)
+
+// Validator can be implemented by structs passed to BindStruct to validate
+// the flag values before the command runs.
+type Validator interface {
+	Validate() error
+}
+
+// BindStruct defines persistent flags for the exported fields of the struct
+// that v points to. The flags are derived from these struct tags:
+//
+//   - flag: name of the flag. Fields without this tag are ignored, except for
+//     nested structs. On a nested struct the name is used as prefix for its
+//     flags, eg `flag:"redis"` results in --redis-address.
+//   - usage: help text of the flag.
+//   - short: single letter shorthand of the flag.
+//   - default: default value of the flag. Without the tag, the current value
+//     of the field is the default.
+//   - env: environment variable that sets the flag, if it was not set on the
+//     command line. See also WithConfig.
+//   - required: "true" if the flag must be set. It cannot be combined with
+//     default, because the flag would never be missing.
+//
+// Supported field types are string, bool, int, int64, uint, uint64, float64,
+// time.Duration, []string, []int, []time.Duration, map[string]string and
+// map[string]int. Slice values are comma separated and map values are
+// key=value pairs.
+//
+// Before the command or any of its subcommands runs, BindStruct checks the
+// required flags and calls Validate, if v implements Validator. The command
+// exits with ExitCodeUsage, if the validation fails. The check is done in a
+// PersistentPreRunE, which calls the previous hook of the command first.
+//
+//	type Runner struct {
+//	    Name  string        `flag:"name" default:"World" usage:"Your name."`
+//	    Redis struct {
+//	        Address string `flag:"address" required:"true" usage:"Redis server address."`
+//	    } `flag:"redis"`
+//	    Timeout time.Duration `flag:"timeout" default:"5s" env:"TIMEOUT" usage:"Request timeout."`
+//	}
+//
+//	func (r *Runner) Bind(cmd *cobra.Command) error {
+//	    return cmdutil.BindStruct(cmd, r)
+//	}
+func BindStruct(cmd *cobra.Command, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("BindStruct requires a pointer to a struct, got %T", v)
+	}
+
+	var names []string
+	err := bindStructFields(cmd.PersistentFlags(), rv.Elem(), "", &names)
+	if err != nil {
+		return err
+	}
+
+	// Required flags are not marked with cobra.MarkFlagRequired, because
+	// cobra validates them before the config gets applied. The validation
+	// needs to be a persistent hook, because the flags are persistent too.
+	preRun, preRunE := cmd.PersistentPreRun, cmd.PersistentPreRunE
+	cmd.PersistentPreRun = nil
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// Same precedence as cobra.
+		switch {
+		case preRunE != nil:
+			err := preRunE(cmd, args)
+			if err != nil {
+				return err
+			}
+		case preRun != nil:
+			preRun(cmd, args)
+		}
+
+		must(applyConfig(cmd))
+
+		err := validateStruct(cmd.Flags(), names, v)
+		if err != nil {
+			slog.Error("invalid flags", "error", err)
+			Exit(ExitCodeUsage)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func bindStructFields(flags *pflag.FlagSet, v reflect.Value, prefix string, names *[]string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, tagged := field.Tag.Lookup("flag")
+		if name == "-" {
+			continue
+		}
+		if tagged && prefix != "" {
+			name = prefix + "-" + name
+		}
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeFor[time.Time]() {
+			if !tagged {
+				name = prefix
+			}
+
+			err := bindStructFields(flags, v.Field(i), name, names)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !tagged {
+			continue
+		}
+
+		err := bindStructField(flags, v.Field(i), field, name)
+		if err != nil {
+			return errors.Wrapf(err, "bind field %s to --%s", field.Name, name)
+		}
+
+		*names = append(*names, name)
+	}
+
+	return nil
+}
+
+func bindStructField(flags *pflag.FlagSet, v reflect.Value, field reflect.StructField, name string) error {
+	short := field.Tag.Get("short")
+	usage := field.Tag.Get("usage")
+
+	_, hasDefault := field.Tag.Lookup("default")
+	if hasDefault && field.Tag.Get("required") == "true" {
+		return errors.Errorf("flag --%s cannot be required and have a default", name)
+	}
+
+	// The default gets parsed by a scratch flag, so it supports the same
+	// syntax as the command line. Setting it on the actual flag would make
+	// slices and maps append to the default instead of replacing it.
+	if def, ok := field.Tag.Lookup("default"); ok {
+		scratch := pflag.NewFlagSet(name, pflag.ContinueOnError)
+		err := defineFlag(scratch, v, name, "", "")
+		if err != nil {
+			return err
+		}
+
+		err = scratch.Set(name, def)
+		if err != nil {
+			return errors.Wrapf(err, "invalid default %q", def)
+		}
+	}
+
+	err := defineFlag(flags, v, name, short, usage)
+	if err != nil {
+		return err
+	}
+
+	if env := field.Tag.Get("env"); env != "" {
+		must(flags.SetAnnotation(name, flagAnnotationEnv, []string{env}))
+	}
+
+	if field.Tag.Get("required") == "true" {
+		must(flags.SetAnnotation(name, flagAnnotationRequired, []string{"true"}))
+	}
+
+	return nil
+}
+
+// defineFlag defines a flag that writes into v. The current value of v is
+// the default of the flag.
+func defineFlag(flags *pflag.FlagSet, v reflect.Value, name, short, usage string) error {
+	switch p := v.Addr().Interface().(type) {
+	case *string:
+		flags.StringVarP(p, name, short, *p, usage)
+	case *bool:
+		flags.BoolVarP(p, name, short, *p, usage)
+	case *int:
+		flags.IntVarP(p, name, short, *p, usage)
+	case *int64:
+		flags.Int64VarP(p, name, short, *p, usage)
+	case *uint:
+		flags.UintVarP(p, name, short, *p, usage)
+	case *uint64:
+		flags.Uint64VarP(p, name, short, *p, usage)
+	case *float64:
+		flags.Float64VarP(p, name, short, *p, usage)
+	case *time.Duration:
+		flags.DurationVarP(p, name, short, *p, usage)
+	case *[]string:
+		flags.StringSliceVarP(p, name, short, *p, usage)
+	case *[]int:
+		flags.IntSliceVarP(p, name, short, *p, usage)
+	case *[]time.Duration:
+		flags.DurationSliceVarP(p, name, short, *p, usage)
+	case *map[string]string:
+		flags.StringToStringVarP(p, name, short, *p, usage)
+	case *map[string]int:
+		flags.StringToIntVarP(p, name, short, *p, usage)
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// applyEnvAnnotations sets all flags, that were not set on the command line,
+// from the environment variable given by the env tag of BindStruct. It is
+// used, when the command does not use WithConfig.
+func applyEnvAnnotations(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+
+		env := flagEnv(f)
+		if env == "" {
+			return
+		}
+
+		if value, ok := os.LookupEnv(env); ok {
+			err = errors.Wrapf(setFlag(flags, f, value), "set flag --%s from %s", f.Name, env)
+		}
+	})
+	return err
+}
+
+func flagEnv(f *pflag.Flag) string {
+	if env := f.Annotations[flagAnnotationEnv]; len(env) > 0 {
+		return env[0]
+	}
+	return ""
+}
+
+func validateStruct(flags *pflag.FlagSet, names []string, v any) error {
+	var missing []string
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed || len(f.Annotations[flagAnnotationRequired]) == 0 {
+			continue
+		}
+
+		missing = append(missing, "--"+name)
+	}
+
+	// The errors get printed to the user, therefore they have no stack trace.
+	if len(missing) > 0 {
+		return fmt.Errorf("required flags not set: %s", strings.Join(missing, ", "))
+	}
+
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package cmdutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindTestStruct struct {
+	Name     string            `flag:"name" short:"n" default:"World" usage:"Your name."`
+	Verbose  bool              `flag:"verbose"`
+	Count    int               `flag:"count" default:"3"`
+	Timeout  time.Duration     `flag:"timeout" default:"5s" env:"BIND_TEST_TIMEOUT"`
+	Tags     []string          `flag:"tags" default:"a,b"`
+	Ports    []int             `flag:"ports" default:"80,443"`
+	Labels   map[string]string `flag:"labels" default:"env=prod,team=ops"`
+	Limits   map[string]int    `flag:"limits" default:"cpu=2"`
+	Ignored  string            `flag:"-"`
+	Untagged string
+	hidden   string `flag:"hidden"`
+
+	Redis struct {
+		Address string `flag:"address" required:"true" usage:"Redis server address."`
+		DB      int    `flag:"db"`
+	} `flag:"redis"`
+
+	Embedded struct {
+		Level string `flag:"level" default:"info"`
+	}
+}
+
+func TestBindStructFlags(t *testing.T) {
+	v := new(bindTestStruct)
+	v.Redis.DB = 7
+
+	cmd := &cobra.Command{Use: "test"}
+	require.NoError(t, BindStruct(cmd, v))
+
+	flags := cmd.PersistentFlags()
+
+	cases := []struct {
+		name  string
+		short string
+		def   string
+		usage string
+	}{
+		{name: "name", short: "n", def: "World", usage: "Your name."},
+		{name: "verbose", def: "false"},
+		{name: "count", def: "3"},
+		{name: "timeout", def: "5s"},
+		{name: "tags", def: "[a,b]"},
+		{name: "ports", def: "[80,443]"},
+		{name: "labels", def: "[env=prod,team=ops]"},
+		{name: "limits", def: "[cpu=2]"},
+		{name: "redis-address", usage: "Redis server address."},
+		{name: "redis-db", def: "7"},
+		{name: "level", def: "info"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := flags.Lookup(tc.name)
+			require.NotNil(t, f)
+			assert.Equal(t, tc.short, f.Shorthand)
+			assert.Equal(t, tc.def, f.DefValue)
+			assert.Equal(t, tc.usage, f.Usage)
+		})
+	}
+
+	for _, name := range []string{"-", "ignored", "untagged", "Untagged", "hidden"} {
+		assert.Nil(t, flags.Lookup(name), name)
+	}
+
+	assert.Equal(t, []string{"BIND_TEST_TIMEOUT"}, flags.Lookup("timeout").Annotations[flagAnnotationEnv])
+	assert.Equal(t, []string{"true"}, flags.Lookup("redis-address").Annotations[flagAnnotationRequired])
+
+	assert.Equal(t, "World", v.Name)
+	assert.Equal(t, 3, v.Count)
+	assert.Equal(t, 5*time.Second, v.Timeout)
+	assert.Equal(t, []string{"a", "b"}, v.Tags)
+	assert.Equal(t, []int{80, 443}, v.Ports)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "ops"}, v.Labels)
+	assert.Equal(t, map[string]int{"cpu": 2}, v.Limits)
+}
+
+func TestBindStructReplacesDefaults(t *testing.T) {
+	v := new(bindTestStruct)
+
+	cmd := &cobra.Command{Use: "test"}
+	require.NoError(t, BindStruct(cmd, v))
+	require.NoError(t, cmd.PersistentFlags().Parse([]string{
+		"--tags", "x",
+		"--labels", "env=dev",
+		"--redis-address", "localhost:6379",
+	}))
+
+	assert.Equal(t, []string{"x"}, v.Tags)
+	assert.Equal(t, map[string]string{"env": "dev"}, v.Labels)
+	assert.Equal(t, "localhost:6379", v.Redis.Address)
+}
+
+func TestBindStructErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		v    any
+	}{
+		{name: "no pointer", v: bindTestStruct{}},
+		{name: "nil pointer", v: (*bindTestStruct)(nil)},
+		{name: "no struct", v: new(string)},
+		{name: "unsupported type", v: &struct {
+			Values []bool `flag:"values"`
+		}{}},
+		{name: "invalid default", v: &struct {
+			Count int `flag:"count" default:"many"`
+		}{}},
+		{name: "required with default", v: &struct {
+			Count int `flag:"count" default:"3" required:"true"`
+		}{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "test"}
+			assert.Error(t, BindStruct(cmd, tc.v))
+		})
+	}
+}
+
+type bindValidatorStruct struct {
+	Min int `flag:"min"`
+	Max int `flag:"max" required:"true"`
+}
+
+func (v *bindValidatorStruct) Validate() error {
+	if v.Min > v.Max {
+		return errors.New("min must not be greater than max")
+	}
+	return nil
+}
+
+func TestBindStructValidation(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing required flag",
+			args:    []string{"--min", "1"},
+			wantErr: "required flags not set: --max",
+		},
+		{
+			name: "valid",
+			args: []string{"--min", "1", "--max", "2"},
+		},
+		{
+			name:    "validator fails",
+			args:    []string{"--min", "3", "--max", "2"},
+			wantErr: "min must not be greater than max",
+		},
+		{
+			name: "required flag from config",
+			args: []string{"--min", "1"},
+			env:  map[string]string{"BINDTEST_MAX": "2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			v := new(bindValidatorStruct)
+			cmd := &cobra.Command{Use: "bindtest"}
+			require.NoError(t, WithConfig(WithEnvPrefix("BINDTEST"))(cmd))
+			require.NoError(t, BindStruct(cmd, v))
+			require.NoError(t, cmd.ParseFlags(tc.args))
+			require.NoError(t, applyConfig(cmd))
+
+			err := validateStruct(cmd.Flags(), []string{"min", "max"}, v)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBindStructEnvWithoutConfig(t *testing.T) {
+	t.Setenv("BIND_TEST_TIMEOUT", "1m")
+
+	v := new(bindTestStruct)
+	cmd := &cobra.Command{Use: "test"}
+	require.NoError(t, BindStruct(cmd, v))
+	require.NoError(t, cmd.ParseFlags(nil))
+	require.NoError(t, applyConfig(cmd))
+
+	assert.Equal(t, time.Minute, v.Timeout)
+}
+
+func TestBindStructExitsOnInvalidFlags(t *testing.T) {
+	v := new(bindValidatorStruct)
+	cmd := &cobra.Command{Use: "test"}
+	require.NoError(t, BindStruct(cmd, v))
+	require.NoError(t, cmd.ParseFlags(nil))
+
+	defer func() {
+		assert.Equal(t, exitCode{code: ExitCodeUsage}, recover())
+	}()
+
+	_ = cmd.PersistentPreRunE(cmd, nil)
+	t.Fatal("PersistentPreRunE did not exit")
+}
+
+func TestBindStructValidatesSubcommands(t *testing.T) {
+	var (
+		v     = new(bindValidatorStruct)
+		hooks []string
+	)
+
+	newCommand := func() *cobra.Command {
+		cmd := New("test", "test command",
+			func(cmd *cobra.Command) error {
+				cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+					hooks = append(hooks, "previous")
+				}
+				return BindStruct(cmd, v)
+			},
+		)
+		cmd.AddCommand(&cobra.Command{
+			Use: "sub",
+			Run: func(cmd *cobra.Command, args []string) {
+				hooks = append(hooks, "run")
+			},
+		})
+		return cmd
+	}
+
+	cmd := newCommand()
+	cmd.SetArgs([]string{"sub", "--max", "2"})
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, []string{"previous", "run"}, hooks)
+	assert.Equal(t, 2, v.Max)
+
+	defer func() {
+		assert.Equal(t, exitCode{code: ExitCodeUsage}, recover())
+	}()
+
+	cmd = newCommand()
+	cmd.SetArgs([]string{"sub"})
+	_ = cmd.Execute()
+	t.Fatal("missing required flag did not exit")
+}
@@ -16,7 +16,7 @@ func New(use, desc string, options ...Option) *cobra.Command {
 
 	var (
 		preRuns           = make([]func(*cobra.Command, []string), 0)
-		persistentPreRuns = make([]func(*cobra.Command, []string), 0)
+		persistentPreRuns = make([]func(*cobra.Command, []string) error, 0)
 	)
 
 	for _, o := range options {
@@ -28,18 +28,37 @@ func New(use, desc string, options ...Option) *cobra.Command {
 		}
 		cmd.PreRun = nil
 
-		if cmd.PersistentPreRun != nil {
-			persistentPreRuns = append(persistentPreRuns, cmd.PersistentPreRun)
+		// Cobra ignores the PersistentPreRun, if there is a
+		// PersistentPreRunE.
+		switch run := cmd.PersistentPreRun; {
+		case cmd.PersistentPreRunE != nil:
+			persistentPreRuns = append(persistentPreRuns, cmd.PersistentPreRunE)
+		case run != nil:
+			persistentPreRuns = append(persistentPreRuns, func(cmd *cobra.Command, args []string) error {
+				run(cmd, args)
+				return nil
+			})
 		}
 
 		cmd.PersistentPreRun = nil
+		cmd.PersistentPreRunE = nil
 	}
 
-	if len(persistentPreRuns) > 0 {
-		cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+	// Cobra only runs the PersistentPreRun of the nearest command, therefore
+	// the config needs to be applied by every command that has one. Commands
+	// without one inherit it from their parent.
+	if len(persistentPreRuns) > 0 || configLoaderOf(cmd) != nil {
+		cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+			must(applyConfig(cmd))
+
 			for _, run := range persistentPreRuns {
-				run(cmd, args)
+				err := run(cmd, args)
+				if err != nil {
+					return err
+				}
 			}
+
+			return nil
 		}
 	}
 
@@ -70,7 +89,10 @@ type Runner interface {
 // prepare Cobra flags.
 func WithRunner(runner Runner) Option {
 	return func(cmd *cobra.Command) error {
-		runner.Bind(cmd)
+		err := runner.Bind(cmd)
+		if err != nil {
+			return err
+		}
 
 		cmd.Run = func(cmd *cobra.Command, args []string) {
 			ctx := SignalRootContext()
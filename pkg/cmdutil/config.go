@@ -0,0 +1,435 @@
+package cmdutil
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// flagAnnotationSecret marks a flag, that must be redacted by the config dump.
+// See MarkFlagSecret.
+const flagAnnotationSecret = "cmdutil_secret"
+
+// secretFlagHints are parts of flag names, that mark the flag as secret,
+// without calling MarkFlagSecret.
+var secretFlagHints = []string{"password", "secret", "token", "credential", "private-key"}
+
+// Sources of a flag value, as shown by the config dump.
+const (
+	configSourceFlag    = "flag"
+	configSourceEnv     = "env"
+	configSourceFile    = "file"
+	configSourceDefault = "default"
+)
+
+type configLoader struct {
+	envPrefix string
+	file      string
+
+	mu      sync.Mutex
+	sources map[*pflag.Flag]string
+	values  map[string]string
+}
+
+// configFileValue is the value of the --config flag. It carries the loader, so
+// the subcommands can find it through the flags of their parents.
+type configFileValue struct {
+	loader *configLoader
+}
+
+func (v *configFileValue) String() string     { return v.loader.file }
+func (v *configFileValue) Set(s string) error { v.loader.file = s; return nil }
+func (v *configFileValue) Type() string       { return "string" }
+
+type ConfigOption func(*configLoader)
+
+// WithEnvPrefix sets the prefix for the environment variables. Defaults to
+// the upper-cased Name build variable or the name of the root command, if the
+// variable is not set.
+func WithEnvPrefix(prefix string) ConfigOption {
+	return func(l *configLoader) {
+		l.envPrefix = prefix
+	}
+}
+
+// WithConfig loads flag values from environment variables and from an
+// optional config file. The environment variable of a flag is the prefix
+// followed by the upper-cased flag name, eg APPNAME_REDIS_ADDRESS for
+// --redis-address.
+//
+// The config file is given with the --config flag (or the APPNAME_CONFIG
+// environment variable) and can be a YAML or TOML file. Its keys are the flag
+// names. Nested keys are joined with a dash, so redis.address also sets
+// --redis-address.
+//
+// Values are applied with the precedence flag > env > file > default before
+// any PreRun of the commands. It also adds a "config dump" subcommand, which
+// prints the effective configuration of all commands with secrets redacted.
+// See MarkFlagSecret.
+func WithConfig(opts ...ConfigOption) Option {
+	return func(cmd *cobra.Command) error {
+		loader := &configLoader{
+			sources: map[*pflag.Flag]string{},
+		}
+
+		for _, o := range opts {
+			o(loader)
+		}
+
+		cmd.PersistentFlags().Var(
+			&configFileValue{loader: loader}, "config",
+			`Path to a YAML or TOML config file. Its values get overridden by environment variables and flags.`)
+
+		cmd.AddCommand(newConfigCommand(loader))
+
+		return nil
+	}
+}
+
+// MarkFlagSecret marks a flag as secret, so its value gets redacted by the
+// config dump. Flags with names containing eg "password", "secret" or "token"
+// are redacted anyway.
+func MarkFlagSecret(flags *pflag.FlagSet, name string) error {
+	return flags.SetAnnotation(name, flagAnnotationSecret, []string{"true"})
+}
+
+// applyConfig applies the environment variables and the config file to the
+// flags of the executed command, if any of its parents uses WithConfig.
+// Otherwise only the env tags of BindStruct are applied.
+func applyConfig(cmd *cobra.Command) error {
+	var loader *configLoader
+	for c := cmd; c != nil && loader == nil; c = c.Parent() {
+		loader = configLoaderOf(c)
+	}
+
+	if loader == nil {
+		return applyEnvAnnotations(cmd.Flags())
+	}
+
+	return loader.apply(cmd.Root(), cmd.Flags())
+}
+
+// configLoaderOf returns the loader that was registered by WithConfig for
+// exactly this command.
+func configLoaderOf(cmd *cobra.Command) *configLoader {
+	f := cmd.PersistentFlags().Lookup("config")
+	if f == nil {
+		return nil
+	}
+
+	value, ok := f.Value.(*configFileValue)
+	if !ok {
+		return nil
+	}
+
+	return value.loader
+}
+
+func (l *configLoader) prefix(root *cobra.Command) string {
+	prefix := l.envPrefix
+	if prefix == "" && Name != "unknown" {
+		prefix = Name
+	}
+	if prefix == "" {
+		prefix = root.Name()
+	}
+
+	return envName(prefix)
+}
+
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// apply sets all flags, that were not set on the command line, from the
+// environment or the config file. It is safe to call it multiple times.
+func (l *configLoader) apply(root *cobra.Command, flags *pflag.FlagSet) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prefix := l.prefix(root)
+
+	// The config flag itself can also be set by the environment, therefore
+	// it needs to be applied before loading the file.
+	if f := flags.Lookup("config"); f != nil {
+		err := l.applyFlag(flags, f, prefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.values == nil {
+		values, err := readConfigFile(l.file)
+		if err != nil {
+			return err
+		}
+		l.values = values
+
+		known := allFlagNames(root)
+		for key := range values {
+			if !known[key] {
+				slog.Warn("ignoring unknown key in config file", "key", key, "file", l.file)
+			}
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err == nil {
+			err = l.applyFlag(flags, f, prefix)
+		}
+	})
+
+	return err
+}
+
+// allFlagNames returns the names of the flags of the command and all its
+// subcommands.
+func allFlagNames(cmd *cobra.Command) map[string]bool {
+	names := map[string]bool{}
+	_ = walkCommands(cmd, func(c *cobra.Command) error {
+		c.LocalFlags().VisitAll(func(f *pflag.Flag) {
+			names[f.Name] = true
+		})
+		return nil
+	})
+
+	return names
+}
+
+// walkCommands calls fn for the command and all its subcommands, parents
+// first. The help and completion commands are skipped, because their flags
+// are no configuration.
+func walkCommands(cmd *cobra.Command, fn func(*cobra.Command) error) error {
+	err := fn(cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == "help" || sub.Name() == "completion" {
+			continue
+		}
+
+		err := walkCommands(sub, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *configLoader) applyFlag(flags *pflag.FlagSet, f *pflag.Flag, prefix string) error {
+	if _, ok := l.sources[f]; ok {
+		return nil
+	}
+
+	if f.Changed {
+		l.sources[f] = configSourceFlag
+		return nil
+	}
+
+	for _, env := range []string{flagEnv(f), prefix + "_" + envName(f.Name)} {
+		if env == "" {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(env); ok {
+			l.sources[f] = configSourceEnv
+			return errors.Wrapf(setFlag(flags, f, value), "set flag --%s from %s", f.Name, env)
+		}
+	}
+
+	if value, ok := l.values[f.Name]; ok {
+		l.sources[f] = configSourceFile
+		return errors.Wrapf(setFlag(flags, f, value), "set flag --%s from config file", f.Name)
+	}
+
+	if f.Name != "config" {
+		l.sources[f] = configSourceDefault
+	}
+
+	return nil
+}
+
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, value string) error {
+	// Slices get replaced as a whole, instead of appending to the defaults.
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		err := sv.Replace(splitList(value))
+		if err != nil {
+			return err
+		}
+		f.Changed = true
+		return nil
+	}
+
+	return flags.Set(f.Name, value)
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+
+	return strings.Split(value, ",")
+}
+
+// readConfigFile reads the YAML or TOML file and returns the flattened
+// values. An empty path results in no values.
+func readConfigFile(path string) (map[string]string, error) {
+	values := map[string]string{}
+	if path == "" {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read config file")
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".yaml", ".yml", ".json":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, errors.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse config file %s", path)
+	}
+
+	flattenConfig(values, "", raw)
+	return values, nil
+}
+
+func flattenConfig(dst map[string]string, prefix string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "-" + key
+			}
+			flattenConfig(dst, name, child)
+		}
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		dst[prefix] = strings.Join(items, ",")
+	case nil:
+		dst[prefix] = ""
+	default:
+		dst[prefix] = fmt.Sprint(v)
+	}
+}
+
+func isSecretFlag(f *pflag.Flag) bool {
+	if len(f.Annotations[flagAnnotationSecret]) > 0 {
+		return true
+	}
+
+	name := strings.ToLower(f.Name)
+	return slices.ContainsFunc(secretFlagHints, func(hint string) bool {
+		return strings.Contains(name, hint)
+	})
+}
+
+func newConfigCommand(loader *configLoader) *cobra.Command {
+	// The config of the parent command must not be validated, because the
+	// dump should also help with invalid configs.
+	cmd := &cobra.Command{
+		Use:              "config",
+		Short:            "Inspects the configuration of this application",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "dump",
+		Short: "Prints the effective configuration with secrets redacted",
+		Long: "Prints the effective configuration with secrets redacted. It contains " +
+			"the flags of all commands. Flags of subcommands are marked with the command.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			doc := &yaml.Node{Kind: yaml.MappingNode}
+			seen := map[string]bool{}
+
+			err := walkCommands(root, func(c *cobra.Command) error {
+				flags := c.LocalFlags()
+
+				err := loader.apply(root, flags)
+				if err != nil {
+					return err
+				}
+
+				flags.VisitAll(func(f *pflag.Flag) {
+					if f.Name == "config" || f.Name == "help" || seen[f.Name] {
+						return
+					}
+					seen[f.Name] = true
+
+					value := f.Value.String()
+					if sv, ok := f.Value.(pflag.SliceValue); ok {
+						value = strings.Join(sv.GetSlice(), ",")
+					}
+					if isSecretFlag(f) && value != "" {
+						value = "<redacted>"
+					}
+
+					loader.mu.Lock()
+					comment := loader.sources[f]
+					loader.mu.Unlock()
+
+					if c != root {
+						comment = fmt.Sprintf("%s (%s)", comment, c.CommandPath())
+					}
+
+					doc.Content = append(doc.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: f.Name},
+						&yaml.Node{Kind: yaml.ScalarNode, Value: value, LineComment: comment},
+					)
+				})
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			enc := yaml.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent(2)
+			err = enc.Encode(doc)
+			if err != nil {
+				return errors.Wrap(err, "encode config")
+			}
+
+			return errors.WithStack(enc.Close())
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,293 @@
+package cmdutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newConfigTestCommand creates a command with WithConfig and the flags
+// --name and --tags. The values are written into the returned struct, when
+// the command runs.
+func newConfigTestCommand(t *testing.T) (*cobra.Command, *configTestValues) {
+	t.Helper()
+
+	values := new(configTestValues)
+	cmd := New("testapp", "test application",
+		WithConfig(WithEnvPrefix("TESTAPP")),
+		func(cmd *cobra.Command) error {
+			cmd.PersistentFlags().StringVar(&values.name, "name", "default", "")
+			cmd.PersistentFlags().StringSliceVar(&values.tags, "tags", []string{"a", "b"}, "")
+			cmd.PersistentFlags().StringVar(&values.password, "password", "", "")
+			cmd.Run = func(cmd *cobra.Command, args []string) {
+				values.ran = true
+			}
+			return nil
+		},
+	)
+
+	return cmd, values
+}
+
+type configTestValues struct {
+	name     string
+	tags     []string
+	password string
+	ran      bool
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	cases := []struct {
+		name   string
+		flag   string
+		env    string
+		file   string
+		want   string
+		source string
+	}{
+		{name: "default", want: "default", source: configSourceDefault},
+		{name: "file", file: "file", want: "file", source: configSourceFile},
+		{name: "env", env: "env", want: "env", source: configSourceEnv},
+		{name: "env over file", env: "env", file: "file", want: "env", source: configSourceEnv},
+		{name: "flag over env", flag: "flag", env: "env", want: "flag", source: configSourceFlag},
+		{name: "flag over all", flag: "flag", env: "env", file: "file", want: "flag", source: configSourceFlag},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, values := newConfigTestCommand(t)
+
+			var args []string
+			if tc.flag != "" {
+				args = append(args, "--name", tc.flag)
+			}
+			if tc.env != "" {
+				t.Setenv("TESTAPP_NAME", tc.env)
+			}
+			if tc.file != "" {
+				args = append(args, "--config", writeTestFile(t, "config.yaml", "name: "+tc.file+"\n"))
+			}
+
+			cmd.SetArgs(args)
+			require.NoError(t, cmd.Execute())
+			require.True(t, values.ran)
+
+			assert.Equal(t, tc.want, values.name)
+			assert.Equal(t, tc.source, configLoaderOf(cmd).sources[cmd.Flags().Lookup("name")])
+		})
+	}
+}
+
+func TestConfigFileFromEnv(t *testing.T) {
+	cmd, values := newConfigTestCommand(t)
+	t.Setenv("TESTAPP_CONFIG", writeTestFile(t, "config.toml", `name = "toml"`))
+
+	cmd.SetArgs(nil)
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, "toml", values.name)
+}
+
+func TestConfigReplacesSliceDefaults(t *testing.T) {
+	cmd, values := newConfigTestCommand(t)
+	t.Setenv("TESTAPP_TAGS", "x,y")
+
+	cmd.SetArgs(nil)
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, []string{"x", "y"}, values.tags)
+}
+
+func TestReadConfigFile(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: "name: foo\n" +
+				"redis:\n" +
+				"  address: localhost:6379\n" +
+				"  db: 3\n" +
+				"tags: [a, b]\n" +
+				"empty:\n",
+			want: map[string]string{
+				"name":          "foo",
+				"redis-address": "localhost:6379",
+				"redis-db":      "3",
+				"tags":          "a,b",
+				"empty":         "",
+			},
+		},
+		{
+			name: "yml",
+			file: "config.yml",
+			content: "verbose: true\n" +
+				"timeout: 5s\n",
+			want: map[string]string{
+				"verbose": "true",
+				"timeout": "5s",
+			},
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: "name = \"foo\"\n" +
+				"tags = [\"a\", \"b\"]\n" +
+				"\n" +
+				"[redis]\n" +
+				"address = \"localhost:6379\"\n" +
+				"db = 3\n",
+			want: map[string]string{
+				"name":          "foo",
+				"redis-address": "localhost:6379",
+				"redis-db":      "3",
+				"tags":          "a,b",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := readConfigFile(writeTestFile(t, tc.file, tc.content))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, have)
+		})
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "unsupported format", file: "config.ini", content: "name=foo"},
+		{name: "invalid yaml", file: "config.yaml", content: "name: [foo"},
+		{name: "invalid toml", file: "config.toml", content: "name = "},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readConfigFile(writeTestFile(t, tc.file, tc.content))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+	})
+
+	t.Run("no file", func(t *testing.T) {
+		values, err := readConfigFile("")
+		require.NoError(t, err)
+		assert.Empty(t, values)
+	})
+}
+
+func TestEnvName(t *testing.T) {
+	cases := map[string]string{
+		"name":          "NAME",
+		"redis-address": "REDIS_ADDRESS",
+		"my.app":        "MY_APP",
+		"Log2":          "LOG2",
+	}
+
+	for input, want := range cases {
+		assert.Equal(t, want, envName(input), input)
+	}
+}
+
+func TestIsSecretFlag(t *testing.T) {
+	cases := []struct {
+		name   string
+		marked bool
+		want   bool
+	}{
+		{name: "name", want: false},
+		{name: "db-password", want: true},
+		{name: "client-secret", want: true},
+		{name: "api-token", want: true},
+		{name: "tls-private-key", want: true},
+		{name: "dsn", marked: true, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.String(tc.name, "", "")
+			if tc.marked {
+				require.NoError(t, MarkFlagSecret(flags, tc.name))
+			}
+
+			assert.Equal(t, tc.want, isSecretFlag(flags.Lookup(tc.name)))
+		})
+	}
+}
+
+func TestConfigDump(t *testing.T) {
+	cmd, _ := newConfigTestCommand(t)
+	t.Setenv("TESTAPP_PASSWORD", "hunter2")
+
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--name", "foo", "config", "dump"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, ""+
+		"name: foo # flag\n"+
+		"password: <redacted> # env\n"+
+		"tags: a,b # default\n",
+		buf.String())
+}
+
+func TestConfigDumpSubcommands(t *testing.T) {
+	cmd, _ := newConfigTestCommand(t)
+
+	var port int
+	serve := New("serve", "serves things", func(cmd *cobra.Command) error {
+		cmd.Flags().IntVar(&port, "port", 8080, "")
+		cmd.PersistentFlags().String("api-token", "", "")
+		return nil
+	})
+	serve.AddCommand(New("sub", "nested command", func(cmd *cobra.Command) error {
+		cmd.Flags().Bool("dry-run", false, "")
+		return nil
+	}))
+	cmd.AddCommand(serve)
+
+	t.Setenv("TESTAPP_PORT", "9090")
+	t.Setenv("TESTAPP_API_TOKEN", "hunter2")
+
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"config", "dump"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, ""+
+		"name: default # default\n"+
+		"password: # default\n"+
+		"tags: a,b # default\n"+
+		"api-token: <redacted> # env (testapp serve)\n"+
+		"port: 9090 # env (testapp serve)\n"+
+		"dry-run: false # default (testapp serve sub)\n",
+		buf.String())
+}
@@ -134,6 +134,23 @@
 //	    return nil
 //	}
 //
+// ## Struct Tags
+//
+// Instead of defining each flag by hand, Bind can derive them from struct
+// tags with BindStruct. It also supports nested structs as flag prefix,
+// required flags and validation via the Validator interface:
+//
+//	type Runner struct {
+//	    Name  string `flag:"name" default:"World" usage:"Your name."`
+//	    Redis struct {
+//	        Address string `flag:"address" required:"true" usage:"Redis server address."`
+//	    } `flag:"redis"`
+//	}
+//
+//	func (r *Runner) Bind(cmd *cobra.Command) error {
+//	    return cmdutil.BindStruct(cmd, r)
+//	}
+//
 // ## Environment-Specific Runners
 //
 // You can create different environment configurations for your application:
@@ -181,6 +198,41 @@
 // - to be able to mock services for local development
 // - and to define a proper interface for the application launch, which is very helpful for e2e tests.
 //
+// # Configuration
+//
+// With WithConfig all flags can also be set by environment variables and by a
+// YAML or TOML config file, which is passed with --config. The environment
+// variable of a flag is the application Name followed by the flag name, eg
+// MYAPP_REDIS_ADDRESS for --redis-address. The precedence is
+// flag > env > file > default and the values are applied before any PreRun.
+//
+//	cmd := cmdutil.New(
+//	    "myapp", "github.com/org/myapp",
+//	    cmdutil.WithConfig(),
+//	    cmdutil.WithRunner(new(Runner)),
+//	)
+//
+// The added "config dump" subcommand prints the effective configuration of the
+// whole command tree and the source of each value. Values of secret flags are
+// redacted. See MarkFlagSecret.
+//
+// # Logging
+//
+// WithLogLevelFlag adds --log-level and WithLogFormatFlag adds --log-format,
+// which switches between human readable text, JSON and logfmt. The log level
+// can be changed at runtime with logutil.SetLevel and for single subsystems
+// (see logutil.Start) with logutil.SetSubsystemLevel. The admin API of webutil
+// exposes both on /log/level, if it has credentials (see
+// webutil.WithAdminAuth).
+//
+// # Completion and Docs
+//
+// WithCompletionCommand adds a "completion" subcommand for bash, zsh and fish.
+// WithDocsCommand adds the hidden "gen-docs" subcommand, which writes man
+// pages and Markdown docs of the whole command tree into the directory given
+// with --dir. The docs contain the environment variables of each flag and the
+// Build* variables.
+//
 // # Version Command
 //
 // NewRootCommand also attaches NewVersionCommand to the application. It prints
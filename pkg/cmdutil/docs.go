@@ -0,0 +1,208 @@
+package cmdutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+	"github.com/spf13/pflag"
+)
+
+// WithCompletionCommand adds a "completion" subcommand, which prints the
+// shell completion script for bash, zsh or fish. It replaces the default
+// completion command of Cobra.
+func WithCompletionCommand() Option {
+	return func(cmd *cobra.Command) error {
+		cmd.CompletionOptions.DisableDefaultCmd = true
+		cmd.AddCommand(NewCompletionCommand())
+		return nil
+	}
+}
+
+// NewCompletionCommand creates a Cobra command, which prints the shell
+// completion script for the root command.
+func NewCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish",
+		Short: "Prints the shell completion script",
+		Long: "Prints the shell completion script for the given shell. For bash it can be loaded with\n\n" +
+			"  source <(APP completion bash)",
+		ValidArgs:             []string{"bash", "zsh", "fish"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		PersistentPreRun:      func(cmd *cobra.Command, args []string) {},
+		PersistentPostRun:     func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			out := cmd.OutOrStdout()
+
+			switch args[0] {
+			case "bash":
+				return errors.WithStack(root.GenBashCompletionV2(out, true))
+			case "zsh":
+				return errors.WithStack(root.GenZshCompletion(out))
+			case "fish":
+				return errors.WithStack(root.GenFishCompletion(out, true))
+			default:
+				return errors.Errorf("unsupported shell %q", args[0])
+			}
+		},
+	}
+}
+
+// WithDocsCommand adds a hidden "gen-docs" subcommand, which generates man
+// pages and Markdown reference docs for the whole command tree. The docs
+// contain the environment variables of the flags (see WithConfig and
+// BindStruct) and the Build* variables.
+func WithDocsCommand() Option {
+	return func(cmd *cobra.Command) error {
+		cmd.AddCommand(NewDocsCommand())
+		return nil
+	}
+}
+
+// NewDocsCommand creates a Cobra command, which generates man pages and
+// Markdown docs for the root command.
+func NewDocsCommand() *cobra.Command {
+	var (
+		dir     string
+		formats []string
+	)
+
+	cmd := &cobra.Command{
+		Use:               "gen-docs",
+		Short:             "Generates man pages and Markdown docs",
+		Hidden:            true,
+		Args:              cobra.NoArgs,
+		PersistentPreRun:  func(cmd *cobra.Command, args []string) {},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			root.DisableAutoGenTag = true
+			addEnvToUsage(root)
+
+			for _, format := range formats {
+				err := genDocs(root, filepath.Join(dir, format), format)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&dir, "dir", "docs",
+		`Directory for the generated docs. Each format gets its own subdirectory.`)
+	cmd.Flags().StringSliceVar(
+		&formats, "format", []string{"man", "markdown"},
+		`Formats of the generated docs. Supported are "man" and "markdown".`)
+
+	return cmd
+}
+
+func genDocs(root *cobra.Command, dir, format string) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return errors.Wrap(err, "create docs directory")
+	}
+
+	switch format {
+	case "man":
+		header := &doc.GenManHeader{
+			Title:   strings.ToUpper(root.Name()),
+			Section: "1",
+			Source:  strings.TrimSpace(fmt.Sprintf("%s %s", Name, Version)),
+			Manual:  GoModule,
+		}
+		if date, err := time.Parse(time.RFC3339, CommitDate); err == nil {
+			header.Date = &date
+		}
+
+		return errors.Wrap(doc.GenManTree(root, header, dir), "generate man pages")
+
+	case "markdown":
+		return errors.Wrap(doc.GenMarkdownTreeCustom(root, dir, markdownVersionHeader,
+			func(name string) string { return name },
+		), "generate markdown docs")
+
+	default:
+		return errors.Errorf("unsupported docs format %q", format)
+	}
+}
+
+func markdownVersionHeader(string) string {
+	return fmt.Sprintf(
+		"<!--\n"+
+			"Generated by gen-docs. Do not edit.\n\n"+
+			"Name:       %s\n"+
+			"Version:    %s\n"+
+			"GoModule:   %s\n"+
+			"SDKVersion: %s\n"+
+			"BuildDate:  %s\n"+
+			"CommitDate: %s\n"+
+			"CommitHash: %s\n"+
+			"-->\n\n",
+		Name, Version, GoModule, SDKVersion, BuildDate, CommitDate, CommitHash)
+}
+
+// addEnvToUsage appends the environment variables to the usage of all flags
+// in the command tree.
+func addEnvToUsage(root *cobra.Command) {
+	var prefix string
+	if loader := configLoaderOf(root); loader != nil {
+		prefix = loader.prefix(root)
+	}
+
+	seen := map[*pflag.Flag]bool{}
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		for _, flags := range []*pflag.FlagSet{cmd.LocalNonPersistentFlags(), cmd.PersistentFlags()} {
+			flags.VisitAll(func(f *pflag.Flag) {
+				if seen[f] {
+					return
+				}
+				seen[f] = true
+
+				envs := flagEnvNames(f, prefix)
+				if len(envs) > 0 {
+					f.Usage = strings.TrimSpace(fmt.Sprintf("%s [env: %s]", f.Usage, strings.Join(envs, ", ")))
+				}
+			})
+		}
+
+		for _, sub := range cmd.Commands() {
+			visit(sub)
+		}
+	}
+	visit(root)
+}
+
+// flagEnvNames returns the environment variables, that can set the flag. The
+// prefix is empty, if the application does not use WithConfig.
+func flagEnvNames(f *pflag.Flag, prefix string) []string {
+	if f.Name == "help" {
+		return nil
+	}
+
+	var envs []string
+	if env := flagEnv(f); env != "" {
+		envs = append(envs, env)
+	}
+
+	if prefix != "" {
+		env := prefix + "_" + envName(f.Name)
+		if !slices.Contains(envs, env) {
+			envs = append(envs, env)
+		}
+	}
+
+	return envs
+}
@@ -0,0 +1,154 @@
+package cmdutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type docsTestRunner struct {
+	Address string `flag:"address" env:"DOCS_TEST_ADDRESS" usage:"Listen address."`
+	Name    string `flag:"name" usage:"Your name."`
+}
+
+func newDocsTestCommand(t *testing.T, opts ...Option) *cobra.Command {
+	t.Helper()
+
+	runner := new(docsTestRunner)
+	opts = append(opts,
+		func(cmd *cobra.Command) error {
+			return BindStruct(cmd, runner)
+		},
+		WithSubCommand(&cobra.Command{
+			Use:   "serve",
+			Short: "Serves something",
+			Run:   func(cmd *cobra.Command, args []string) {},
+		}),
+		WithCompletionCommand(),
+		WithDocsCommand(),
+	)
+
+	cmd := New("docstest", "test application", opts...)
+	cmd.Run = func(cmd *cobra.Command, args []string) {}
+	return cmd
+}
+
+func TestAddEnvToUsage(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			name: "without config",
+			want: map[string]string{
+				"address": "Listen address. [env: DOCS_TEST_ADDRESS]",
+				"name":    "Your name.",
+			},
+		},
+		{
+			name: "with config",
+			opts: []Option{WithConfig(WithEnvPrefix("DOCSTEST"))},
+			want: map[string]string{
+				"address": "Listen address. [env: DOCS_TEST_ADDRESS, DOCSTEST_ADDRESS]",
+				"name":    "Your name. [env: DOCSTEST_NAME]",
+				"config":  "Path to a YAML or TOML config file. Its values get overridden by environment variables and flags. [env: DOCSTEST_CONFIG]",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newDocsTestCommand(t, tc.opts...)
+			addEnvToUsage(cmd)
+
+			for name, want := range tc.want {
+				f := cmd.PersistentFlags().Lookup(name)
+				require.NotNil(t, f, name)
+				assert.Equal(t, want, f.Usage, name)
+			}
+
+			cmd.InitDefaultHelpFlag()
+			assert.NotContains(t, cmd.Flags().Lookup("help").Usage, "[env:")
+		})
+	}
+}
+
+func TestGenDocs(t *testing.T) {
+	cases := []struct {
+		format string
+		files  []string
+		want   []string
+	}{
+		{
+			format: "markdown",
+			files:  []string{"docstest.md", "docstest_serve.md", "docstest_completion.md"},
+			want:   []string{"Generated by gen-docs. Do not edit.", "[env: DOCS_TEST_ADDRESS]"},
+		},
+		{
+			format: "man",
+			files:  []string{"docstest.1", "docstest-serve.1", "docstest-completion.1"},
+			want:   []string{".TH \"DOCSTEST\" \"1\"", "DOCS_TEST_ADDRESS"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			dir := t.TempDir()
+
+			cmd := newDocsTestCommand(t)
+			cmd.SetArgs([]string{"gen-docs", "--dir", dir, "--format", tc.format})
+			require.NoError(t, cmd.Execute())
+
+			for _, file := range tc.files {
+				assert.FileExists(t, filepath.Join(dir, tc.format, file))
+			}
+
+			assert.NoFileExists(t, filepath.Join(dir, tc.format, "docstest_gen-docs.md"),
+				"hidden commands must not be documented")
+
+			data, err := os.ReadFile(filepath.Join(dir, tc.format, tc.files[0]))
+			require.NoError(t, err)
+			for _, want := range tc.want {
+				assert.Contains(t, string(data), want)
+			}
+		})
+	}
+}
+
+func TestGenDocsUnsupportedFormat(t *testing.T) {
+	cmd := newDocsTestCommand(t)
+	cmd.SetArgs([]string{"gen-docs", "--dir", t.TempDir(), "--format", "html"})
+	cmd.SetErr(new(bytes.Buffer))
+
+	assert.EqualError(t, cmd.Execute(), `unsupported docs format "html"`)
+}
+
+func TestCompletionCommand(t *testing.T) {
+	cases := []struct {
+		shell string
+		want  string
+	}{
+		{shell: "bash", want: "__start_docstest"},
+		{shell: "zsh", want: "#compdef docstest"},
+		{shell: "fish", want: "complete -c docstest"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.shell, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+
+			cmd := newDocsTestCommand(t)
+			cmd.SetOut(buf)
+			cmd.SetArgs([]string{"completion", tc.shell})
+			require.NoError(t, cmd.Execute())
+
+			assert.Contains(t, buf.String(), tc.want)
+		})
+	}
+}
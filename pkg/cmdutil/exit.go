@@ -31,9 +31,14 @@ func Exit(code int) {
 
 // HandleExit recovers from Exit calls and terminates the current program with
 // a proper exit code. It should get deferred at the beginning of the main
-// function.
+// function. Before exiting, it waits for pending log messages to be sent to
+// Graylog (see WithLogToGraylog).
 func HandleExit() {
-	if e := recover(); e != nil {
+	e := recover()
+
+	flushGELFSinks()
+
+	if e != nil {
 		if exit, ok := e.(exitCode); ok {
 			os.Exit(exit.code)
 		}
@@ -0,0 +1,408 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+)
+
+// Supported values of the --gelf-transport flag.
+const (
+	GELFTransportUDP = "udp"
+	GELFTransportTCP = "tcp"
+	GELFTransportTLS = "tls"
+)
+
+const (
+	gelfDialTimeout  = 5 * time.Second
+	gelfWriteTimeout = 5 * time.Second
+	gelfFlushTimeout = 5 * time.Second
+
+	// gelfMaxAttempts is the number of attempts to send a single message,
+	// before it gets dropped.
+	gelfMaxAttempts = 5
+)
+
+var gelfBackoff = runutil.ExponentialBackoff{
+	Initial:          100 * time.Millisecond,
+	Max:              30 * time.Second,
+	JitterProportion: 0.5,
+}
+
+var instGELFMessagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: runutil.PromNamespace,
+	Subsystem: "gelf",
+	Name:      "messages_total",
+}, []string{"result"})
+
+// gelfEntry is an element of the gelfSink queue. It contains either a message
+// or a flush marker, which gets closed after all previous messages were
+// processed.
+type gelfEntry struct {
+	msg     *gelf.Message
+	flushed chan struct{}
+}
+
+// gelfSink sends GELF messages asynchronously to Graylog. Messages get
+// dropped, if the queue is full, so a slow Graylog never blocks the
+// application.
+type gelfSink struct {
+	transport string
+	address   string
+	queue     chan gelfEntry
+
+	// log is used to report connection problems. It must not use the GELF
+	// handler, because that would feed the errors back into the sink.
+	log *slog.Logger
+
+	conn net.Conn
+	udp  *gelf.Writer
+
+	// flushing gets closed, when the application exits. The remaining
+	// messages are only tried once afterwards, so HandleExit does not wait
+	// for retries.
+	flushing     chan struct{}
+	flushingOnce sync.Once
+}
+
+func newGELFSink(transport, address string, bufferSize int) (*gelfSink, error) {
+	switch transport {
+	case GELFTransportUDP, GELFTransportTCP, GELFTransportTLS:
+	default:
+		return nil, errors.Errorf("unsupported GELF transport %q", transport)
+	}
+
+	s := &gelfSink{
+		transport: transport,
+		address:   address,
+		queue:     make(chan gelfEntry, bufferSize),
+		flushing:  make(chan struct{}),
+		log:       slog.New(logutil.NewLevelHandler(newCLIHandler())).With("gelf-address", address),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// enqueue adds the message to the queue without blocking.
+func (s *gelfSink) enqueue(msg *gelf.Message) {
+	select {
+	case s.queue <- gelfEntry{msg: msg}:
+	default:
+		instGELFMessagesTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// flush waits until all queued messages are processed or the context is
+// done. Failed messages are not retried after flush was called.
+func (s *gelfSink) flush(ctx context.Context) {
+	s.flushingOnce.Do(func() { close(s.flushing) })
+
+	flushed := make(chan struct{})
+	select {
+	case s.queue <- gelfEntry{flushed: flushed}:
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+	}
+}
+
+func (s *gelfSink) run() {
+	for entry := range s.queue {
+		if entry.flushed != nil {
+			close(entry.flushed)
+			continue
+		}
+
+		s.send(entry.msg)
+	}
+}
+
+// send writes the message and reconnects on failures. The message gets
+// dropped after gelfMaxAttempts failed attempts. The queue fills up in the
+// meantime and new messages get dropped.
+func (s *gelfSink) send(msg *gelf.Message) {
+	for attempt := 0; attempt < gelfMaxAttempts; attempt++ {
+		if attempt > 0 && !s.wait(attempt) {
+			break
+		}
+
+		err := s.connect()
+		if err != nil {
+			if attempt == 0 {
+				s.log.Error("failed to connect to Graylog", "error", err)
+			}
+			continue
+		}
+
+		err = s.write(msg)
+		if err != nil {
+			if attempt == 0 {
+				s.log.Error("failed to send message to Graylog", "error", err)
+			}
+			s.close()
+			continue
+		}
+
+		if attempt > 0 {
+			s.log.Info("reconnected to Graylog", "attempts", attempt)
+		}
+		instGELFMessagesTotal.WithLabelValues("sent").Inc()
+		return
+	}
+
+	instGELFMessagesTotal.WithLabelValues("failed").Inc()
+}
+
+// wait sleeps for the backoff of the attempt. It returns false, if the sink
+// gets flushed in the meantime.
+func (s *gelfSink) wait(attempt int) bool {
+	timer := time.NewTimer(gelfBackoff.Duration(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.flushing:
+		return false
+	}
+}
+
+// connect dials Graylog, if there is no connection yet. The errors of connect
+// and write have no stack trace, because they only get logged.
+func (s *gelfSink) connect() error {
+	if s.conn != nil || s.udp != nil {
+		return nil
+	}
+
+	var err error
+	switch s.transport {
+	case GELFTransportUDP:
+		s.udp, err = gelf.NewWriter(s.address)
+	case GELFTransportTCP:
+		s.conn, err = net.DialTimeout("tcp", s.address, gelfDialTimeout)
+	case GELFTransportTLS:
+		dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: gelfDialTimeout}}
+		s.conn, err = dialer.Dial("tcp", s.address)
+	}
+
+	return err
+}
+
+func (s *gelfSink) write(msg *gelf.Message) error {
+	if s.udp != nil {
+		return s.udp.WriteMessage(msg)
+	}
+
+	// GELF over TCP does not support compression and the messages are
+	// delimited by a null byte.
+	buf := new(bytes.Buffer)
+	err := msg.MarshalJSONBuf(buf)
+	if err != nil {
+		return err
+	}
+	buf.WriteByte(0)
+
+	err = s.conn.SetWriteDeadline(time.Now().Add(gelfWriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(buf.Bytes())
+	return err
+}
+
+func (s *gelfSink) close() {
+	if s.udp != nil {
+		s.udp.Close()
+		s.udp = nil
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// gelfSinks contains all sinks, so HandleExit can flush them.
+var gelfSinks struct {
+	mu    sync.Mutex
+	sinks []*gelfSink
+}
+
+func registerGELFSink(s *gelfSink) {
+	gelfSinks.mu.Lock()
+	defer gelfSinks.mu.Unlock()
+	gelfSinks.sinks = append(gelfSinks.sinks, s)
+}
+
+// flushGELFSinks waits until all queued log messages are sent to Graylog or
+// the flush timeout is reached. The sinks are flushed concurrently, so they
+// share the timeout.
+func flushGELFSinks() {
+	gelfSinks.mu.Lock()
+	sinks := gelfSinks.sinks
+	gelfSinks.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), gelfFlushTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, s := range sinks {
+		wg.Go(func() { s.flush(ctx) })
+	}
+	wg.Wait()
+}
+
+// gelfHandler is a slog.Handler that converts the records to GELF messages
+// and passes them to the gelfSink.
+type gelfHandler struct {
+	sink     *gelfSink
+	hostname string
+	attrs    map[string]any
+	group    string
+}
+
+func newGELFHandler(sink *gelfSink, hostname string) *gelfHandler {
+	return &gelfHandler{
+		sink:     sink,
+		hostname: hostname,
+		attrs:    map[string]any{},
+	}
+}
+
+func (h *gelfHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *gelfHandler) Handle(_ context.Context, r slog.Record) error {
+	extra := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		extra[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addGELFAttr(extra, h.group, a)
+		return true
+	})
+
+	h.sink.enqueue(&gelf.Message{
+		Version:  "1.1",
+		Host:     h.hostname,
+		Short:    r.Message,
+		TimeUnix: float64(r.Time.UnixNano()) / float64(time.Second),
+		Level:    gelfLevel(r.Level),
+		Extra:    extra,
+	})
+
+	return nil
+}
+
+func (h *gelfHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make(map[string]any, len(h.attrs)+len(attrs))
+	for k, v := range h.attrs {
+		h2.attrs[k] = v
+	}
+	for _, a := range attrs {
+		addGELFAttr(h2.attrs, h.group, a)
+	}
+	return &h2
+}
+
+func (h *gelfHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.group = gelfKey(h.group, name)
+	return &h2
+}
+
+// addGELFAttr adds the attribute as additional field. GELF does not support
+// nested fields, therefore groups are flattened with a dot.
+func addGELFAttr(dst map[string]any, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := gelfKey(group, a.Key)
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		for _, child := range a.Value.Group() {
+			addGELFAttr(dst, key, child)
+		}
+		return
+	case slog.KindString, slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindBool:
+		dst["_"+key] = a.Value.Any()
+	case slog.KindTime:
+		dst["_"+key] = a.Value.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		dst["_"+key] = a.Value.Duration().String()
+	default:
+		if err, ok := a.Value.Any().(error); ok {
+			dst["_"+key] = err.Error()
+			return
+		}
+		dst["_"+key] = fmt.Sprint(a.Value.Any())
+	}
+}
+
+func gelfKey(group, key string) string {
+	// The field "_id" is reserved by Graylog.
+	if group == "" && key == "id" {
+		key = "id_"
+	}
+
+	if group == "" {
+		return key
+	}
+
+	return strings.Join([]string{group, key}, ".")
+}
+
+func gelfLevel(level slog.Level) int32 {
+	switch {
+	case level >= slog.LevelError:
+		return gelf.LOG_ERR
+	case level >= slog.LevelWarn:
+		return gelf.LOG_WARNING
+	case level >= slog.LevelInfo:
+		return gelf.LOG_INFO
+	default:
+		return gelf.LOG_DEBUG
+	}
+}
+
+func gelfHostname(hostname string) string {
+	if hostname != "" {
+		return hostname
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+
+	return hostname
+}
@@ -0,0 +1,286 @@
+package cmdutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+
+	var pb dto.Metric
+	require.NoError(t, c.Write(&pb))
+	return pb.Counter.GetValue()
+}
+
+// newQueueOnlySink creates a sink without the sending goroutine, so the tests
+// can inspect the queued messages.
+func newQueueOnlySink(size int) *gelfSink {
+	return &gelfSink{
+		queue:    make(chan gelfEntry, size),
+		flushing: make(chan struct{}),
+	}
+}
+
+func TestGELFHandlerFields(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want map[string]any
+	}{
+		{
+			name: "kinds",
+			log: func(l *slog.Logger) {
+				l.Info("msg",
+					"string", "foo",
+					"int", 42,
+					"uint", uint64(7),
+					"float", 1.5,
+					"bool", true,
+					"time", timestamp,
+					"duration", 90*time.Second,
+					"error", errors.New("boom"),
+					"any", []int{1, 2},
+				)
+			},
+			want: map[string]any{
+				"_string":   "foo",
+				"_int":      int64(42),
+				"_uint":     uint64(7),
+				"_float":    1.5,
+				"_bool":     true,
+				"_time":     "2024-05-01T12:00:00Z",
+				"_duration": "1m30s",
+				"_error":    "boom",
+				"_any":      "[1 2]",
+			},
+		},
+		{
+			name: "attrs and groups",
+			log: func(l *slog.Logger) {
+				l.With("subsystem", "/worker").
+					WithGroup("job").
+					With("name", "sync").
+					Info("msg", slog.Group("result", "count", 3), "empty", slog.GroupValue())
+			},
+			want: map[string]any{
+				"_subsystem":        "/worker",
+				"_job.name":         "sync",
+				"_job.result.count": int64(3),
+			},
+		},
+		{
+			name: "empty group name",
+			log: func(l *slog.Logger) {
+				l.WithGroup("").Info("msg", "key", "value")
+			},
+			want: map[string]any{
+				"_key": "value",
+			},
+		},
+		{
+			name: "reserved id",
+			log: func(l *slog.Logger) {
+				l.With("id", "a").Info("msg", slog.Group("job", "id", "b"))
+			},
+			want: map[string]any{
+				"_id_":    "a",
+				"_job.id": "b",
+			},
+		},
+		{
+			name: "inline group",
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("", "key", "value"))
+			},
+			want: map[string]any{
+				"_key": "value",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := newQueueOnlySink(1)
+			tc.log(slog.New(newGELFHandler(sink, "test-host")))
+
+			require.Len(t, sink.queue, 1)
+			msg := (<-sink.queue).msg
+
+			assert.Equal(t, "1.1", msg.Version)
+			assert.Equal(t, "test-host", msg.Host)
+			assert.Equal(t, "msg", msg.Short)
+			assert.Equal(t, int32(gelf.LOG_INFO), msg.Level)
+			assert.Equal(t, tc.want, msg.Extra)
+		})
+	}
+}
+
+func TestGELFHandlerDoesNotShareAttrs(t *testing.T) {
+	sink := newQueueOnlySink(2)
+	base := slog.New(newGELFHandler(sink, "test-host")).With("shared", 1)
+
+	base.With("a", 1).Info("first")
+	base.With("b", 2).Info("second")
+
+	assert.Equal(t, map[string]any{"_shared": int64(1), "_a": int64(1)}, (<-sink.queue).msg.Extra)
+	assert.Equal(t, map[string]any{"_shared": int64(1), "_b": int64(2)}, (<-sink.queue).msg.Extra)
+}
+
+func TestGELFLevel(t *testing.T) {
+	cases := []struct {
+		level slog.Level
+		want  int32
+	}{
+		{level: slog.LevelDebug - 4, want: gelf.LOG_DEBUG},
+		{level: slog.LevelDebug, want: gelf.LOG_DEBUG},
+		{level: slog.LevelInfo, want: gelf.LOG_INFO},
+		{level: slog.LevelInfo + 2, want: gelf.LOG_INFO},
+		{level: slog.LevelWarn, want: gelf.LOG_WARNING},
+		{level: slog.LevelError, want: gelf.LOG_ERR},
+		{level: slog.LevelError + 4, want: gelf.LOG_ERR},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			assert.Equal(t, tc.want, gelfLevel(tc.level))
+		})
+	}
+}
+
+func TestGELFSinkDropsWhenFull(t *testing.T) {
+	dropped := instGELFMessagesTotal.WithLabelValues("dropped")
+	before := counterValue(t, dropped)
+
+	sink := newQueueOnlySink(1)
+	sink.enqueue(&gelf.Message{Short: "first"})
+	sink.enqueue(&gelf.Message{Short: "second"})
+
+	assert.Equal(t, before+1, counterValue(t, dropped))
+	assert.Equal(t, "first", (<-sink.queue).msg.Short)
+}
+
+func TestGELFSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var messages []string
+		reader := bufio.NewReader(conn)
+		for len(messages) < 2 {
+			frame, err := reader.ReadBytes(0)
+			if err != nil {
+				break
+			}
+			messages = append(messages, string(frame[:len(frame)-1]))
+		}
+		received <- messages
+	}()
+
+	sent := instGELFMessagesTotal.WithLabelValues("sent")
+	before := counterValue(t, sent)
+
+	sink, err := newGELFSink(GELFTransportTCP, listener.Addr().String(), 10)
+	require.NoError(t, err)
+
+	logger := slog.New(newGELFHandler(sink, "test-host"))
+	logger.Info("first", "id", 1)
+	logger.Warn("second")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sink.flush(ctx)
+
+	var messages []string
+	select {
+	case messages = <-received:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for messages")
+	}
+
+	require.Len(t, messages, 2)
+	assert.Equal(t, before+2, counterValue(t, sent))
+
+	var first map[string]any
+	require.NoError(t, json.Unmarshal([]byte(messages[0]), &first))
+	assert.Equal(t, "first", first["short_message"])
+	assert.Equal(t, "test-host", first["host"])
+	assert.Equal(t, float64(1), first["_id_"])
+
+	var second map[string]any
+	require.NoError(t, json.Unmarshal([]byte(messages[1]), &second))
+	assert.Equal(t, "second", second["short_message"])
+	assert.Equal(t, float64(gelf.LOG_WARNING), second["level"])
+}
+
+// unusedAddress returns a TCP address, that refuses connections.
+func unusedAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	return address
+}
+
+func TestGELFSinkDropsFailedMessages(t *testing.T) {
+	backoff := gelfBackoff
+	gelfBackoff = runutil.ExponentialBackoff{Initial: time.Millisecond, Max: time.Millisecond}
+	t.Cleanup(func() { gelfBackoff = backoff })
+
+	failed := instGELFMessagesTotal.WithLabelValues("failed")
+	before := counterValue(t, failed)
+
+	sink, err := newGELFSink(GELFTransportTCP, unusedAddress(t), 10)
+	require.NoError(t, err)
+	sink.enqueue(&gelf.Message{Short: "lost"})
+
+	assert.Eventually(t, func() bool {
+		return counterValue(t, failed) == before+1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestGELFSinkFlushSkipsRetries(t *testing.T) {
+	failed := instGELFMessagesTotal.WithLabelValues("failed")
+	before := counterValue(t, failed)
+
+	sink, err := newGELFSink(GELFTransportTCP, unusedAddress(t), 10)
+	require.NoError(t, err)
+	for range 3 {
+		sink.enqueue(&gelf.Message{Short: "lost"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	sink.flush(ctx)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, before+3, counterValue(t, failed))
+}
@@ -5,48 +5,92 @@ import (
 	"os"
 	"time"
 
-	"github.com/Graylog2/go-gelf/gelf"
 	"github.com/lmittmann/tint"
-	sloggraylog "github.com/samber/slog-graylog/v2"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 	slogmulti "github.com/samber/slog-multi"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
-// logLevel is the shared slog.LevelVar used to dynamically control the log level.
-var logLevel = new(slog.LevelVar)
-
 // logAddSource controls whether source location is included in log output.
 var logAddSource bool
 
+// Supported values of the --log-format flag. See WithLogFormatFlag.
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+// logFormat is the output format of the CLI handler.
+var logFormat = LogFormatText
+
 // newCLIHandler creates the appropriate CLI handler based on configuration.
-// Otherwise, on a TTY it uses tint for colorized output; on a non-TTY it uses
-// tint without color and with a longer timestamp.
+// With the JSON and logfmt formats it uses the slog handlers. Otherwise, on a
+// TTY it uses tint for colorized output; on a non-TTY it uses tint without
+// color and with a longer timestamp.
+//
+// The handler logs all levels, because the filtering is done by
+// logutil.NewLevelHandler.
 func newCLIHandler() slog.Handler {
+	switch logFormat {
+	case LogFormatJSON:
+		return slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level:     logutil.LevelAll,
+			AddSource: logAddSource,
+		})
+	case LogFormatLogfmt:
+		return slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level:     logutil.LevelAll,
+			AddSource: logAddSource,
+		})
+	}
+
 	if term.IsTerminal(int(os.Stderr.Fd())) {
 		return tint.NewHandler(os.Stderr, &tint.Options{
-			Level:      logLevel,
+			Level:      logutil.LevelAll,
 			TimeFormat: time.TimeOnly,
 			AddSource:  logAddSource,
 		})
 	}
 
 	return tint.NewHandler(os.Stderr, &tint.Options{
-		Level:      logLevel,
+		Level:      logutil.LevelAll,
 		TimeFormat: time.DateTime,
 		NoColor:    true,
 		AddSource:  logAddSource,
 	})
 }
 
+// graylogHandler is the additional handler, that gets set by
+// WithLogToGraylogHostname.
+var graylogHandler slog.Handler
+
 // reconfigureLogger rebuilds the default logger with the current settings.
-// This must be called after any change to logLevel or logAddSource.
+// This must be called after any change to logFormat, logAddSource or
+// graylogHandler.
 func reconfigureLogger() {
-	slog.SetDefault(slog.New(newCLIHandler()))
+	if graylogHandler == nil {
+		slog.SetDefault(slog.New(logutil.NewLevelHandler(newCLIHandler())))
+		return
+	}
+
+	handler := logutil.NewLevelHandler(slogmulti.Fanout(
+		newCLIHandler(),
+		graylogHandler,
+	))
+
+	logger := slog.New(handler).With(
+		"facility", Name,
+		"version", Version,
+		"commit-sha", CommitHash,
+	)
+
+	slog.SetDefault(logger)
 }
 
 func init() {
-	logLevel.Set(slog.LevelInfo)
+	logutil.SetLevel(slog.LevelInfo)
 	reconfigureLogger()
 }
 
@@ -62,12 +106,70 @@ func WithLogVerboseFlag() Option {
 
 		cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 			if enabled {
-				logLevel.Set(slog.LevelDebug)
+				logutil.SetLevel(slog.LevelDebug)
 				logAddSource = true
-			} else {
-				logLevel.Set(slog.LevelInfo)
-				logAddSource = false
+				reconfigureLogger()
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithLogLevelFlag adds a --log-level flag that sets the log level. It
+// accepts the slog level names with an optional offset, eg "debug", "warn" or
+// "info+2". The level is only applied, if the flag was set, so it does not
+// override WithLogVerboseFlag. The level can be changed at runtime with
+// logutil.SetLevel and logutil.SetSubsystemLevel.
+func WithLogLevelFlag() Option {
+	var level string
+
+	return func(cmd *cobra.Command) error {
+		cmd.PersistentFlags().StringVar(
+			&level, "log-level", "info",
+			`Minimum level of printed log messages. One of "debug", "info", "warn" or "error".`)
+
+		cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+			if !cmd.Flags().Changed("log-level") {
+				return
+			}
+
+			l, err := parseLogLevel(level)
+			if err != nil {
+				slog.Error("invalid log level", "error", err, "level", level)
+				Exit(ExitCodeUsage)
 			}
+
+			logutil.SetLevel(l)
+		}
+
+		return nil
+	}
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// WithLogFormatFlag adds a --log-format flag that selects the output format of
+// the logs. Supported are "text" (human readable, colored on a TTY), "json"
+// and "logfmt".
+func WithLogFormatFlag() Option {
+	return func(cmd *cobra.Command) error {
+		cmd.PersistentFlags().StringVar(
+			&logFormat, "log-format", LogFormatText,
+			`Output format of the logs. One of "text", "json" or "logfmt".`)
+
+		cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+			switch logFormat {
+			case LogFormatText, LogFormatJSON, LogFormatLogfmt:
+			default:
+				slog.Error("invalid log format", "format", logFormat)
+				Exit(ExitCodeUsage)
+			}
+
 			reconfigureLogger()
 		}
 
@@ -79,42 +181,48 @@ func WithLogToGraylog() Option {
 	return WithLogToGraylogHostname("")
 }
 
+// WithLogToGraylogHostname adds flags to send the logs additionally to
+// Graylog. The messages are sent asynchronously via UDP, TCP or TLS, depending
+// on the --gelf-transport flag. Messages get dropped, if Graylog is too slow
+// or unavailable, so logging never blocks the application. A message that
+// cannot be sent after a few attempts also gets dropped. The number of sent,
+// dropped and failed messages is exported as Prometheus metric.
+//
+// The hostname is sent as host of the messages. It defaults to the hostname
+// of the machine, if empty. HandleExit waits a few seconds for the remaining
+// messages to be sent.
 func WithLogToGraylogHostname(hostname string) Option {
-	var gelfAddress string
+	var (
+		gelfAddress    string
+		gelfTransport  string
+		gelfBufferSize int
+	)
 
 	return func(cmd *cobra.Command) error {
 		cmd.PersistentFlags().StringVar(
 			&gelfAddress, "gelf-address", "",
 			`Address to Graylog for logging (format: "ip:port").`)
+		cmd.PersistentFlags().StringVar(
+			&gelfTransport, "gelf-transport", GELFTransportUDP,
+			`Transport for sending logs to Graylog. One of "udp", "tcp" or "tls".`)
+		cmd.PersistentFlags().IntVar(
+			&gelfBufferSize, "gelf-buffer-size", 10000,
+			`Maximum number of log messages that are buffered for Graylog. Further messages get dropped.`)
 
 		cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 			if gelfAddress == "" {
 				return
 			}
 
-			gelfWriter, err := gelf.NewWriter(gelfAddress)
+			sink, err := newGELFSink(gelfTransport, gelfAddress, gelfBufferSize)
 			if err != nil {
-				slog.Error("failed to create GELF writer", "error", err, "address", gelfAddress)
-				return
+				slog.Error("failed to create GELF sink", "error", err, "address", gelfAddress)
+				Exit(ExitCodeUsage)
 			}
+			registerGELFSink(sink)
 
-			graylogHandler := sloggraylog.Option{
-				Level:  logLevel,
-				Writer: gelfWriter,
-			}.NewGraylogHandler()
-
-			handler := slogmulti.Fanout(
-				newCLIHandler(),
-				graylogHandler,
-			)
-
-			logger := slog.New(handler).With(
-				"facility", Name,
-				"version", Version,
-				"commit-sha", CommitHash,
-			)
-
-			slog.SetDefault(logger)
+			graylogHandler = newGELFHandler(sink, gelfHostname(hostname))
+			reconfigureLogger()
 		}
 
 		return nil
@@ -0,0 +1,109 @@
+package cmdutil
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetLogger restores the global log settings after the test.
+func resetLogger(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		logutil.SetLevel(slog.LevelInfo)
+		logFormat = LogFormatText
+		logAddSource = false
+		reconfigureLogger()
+	})
+}
+
+// executeLogTestCommand runs a command with the log flags and returns the
+// exit code, if the command called Exit.
+func executeLogTestCommand(t *testing.T, args ...string) (code int) {
+	t.Helper()
+
+	cmd := New("logtest", "test application",
+		WithLogVerboseFlag(),
+		WithLogLevelFlag(),
+		WithLogFormatFlag(),
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {}
+	cmd.SetArgs(args)
+
+	defer func() {
+		if e := recover(); e != nil {
+			exit, ok := e.(exitCode)
+			require.True(t, ok, "unexpected panic: %v", e)
+			code = exit.code
+		}
+	}()
+
+	require.NoError(t, cmd.Execute())
+	return ExitCodeOK
+}
+
+func TestLogLevelFlag(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		want     slog.Level
+		wantCode int
+	}{
+		{name: "default", want: slog.LevelInfo},
+		{name: "debug", args: []string{"--log-level", "debug"}, want: slog.LevelDebug},
+		{name: "upper case", args: []string{"--log-level", "WARN"}, want: slog.LevelWarn},
+		{name: "offset", args: []string{"--log-level", "info+2"}, want: slog.LevelInfo + 2},
+		{name: "verbose", args: []string{"-v"}, want: slog.LevelDebug},
+		{name: "verbose with level", args: []string{"-v", "--log-level", "error"}, want: slog.LevelError},
+		{name: "invalid", args: []string{"--log-level", "loud"}, want: slog.LevelInfo, wantCode: ExitCodeUsage},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetLogger(t)
+
+			code := executeLogTestCommand(t, tc.args...)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, tc.want, logutil.Level())
+		})
+	}
+}
+
+func TestLogFormatFlag(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{name: "default", want: LogFormatText},
+		{name: "json", args: []string{"--log-format", "json"}, want: LogFormatJSON},
+		{name: "logfmt", args: []string{"--log-format", "logfmt"}, want: LogFormatLogfmt},
+		{name: "invalid", args: []string{"--log-format", "xml"}, want: "xml", wantCode: ExitCodeUsage},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetLogger(t)
+
+			code := executeLogTestCommand(t, tc.args...)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, tc.want, logFormat)
+		})
+	}
+}
+
+func TestNewCLIHandler(t *testing.T) {
+	resetLogger(t)
+
+	logFormat = LogFormatJSON
+	assert.IsType(t, new(slog.JSONHandler), newCLIHandler())
+
+	logFormat = LogFormatLogfmt
+	assert.IsType(t, new(slog.TextHandler), newCLIHandler())
+}
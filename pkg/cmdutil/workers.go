@@ -0,0 +1,73 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+// WithWorkerGraphCommand adds a "worker-graph" subcommand, which prints the
+// dependency graph of the workers (see runutil.DescribeProvidedWorkers)
+// without starting them. The provide function must register the workers and
+// their dependencies in the container, like the application does before
+// calling runutil.RunProvidedWorkers.
+func WithWorkerGraphCommand(provide func(ctx context.Context, c *dig.Container) error) Option {
+	return func(cmd *cobra.Command) error {
+		cmd.AddCommand(NewWorkerGraphCommand(provide))
+		return nil
+	}
+}
+
+// NewWorkerGraphCommand creates a Cobra command, which prints the dependency
+// graph of the workers as JSON or, with --format dot, in the Graphviz DOT
+// format. Note that all workers get instantiated by dig.
+func NewWorkerGraphCommand(provide func(ctx context.Context, c *dig.Container) error) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "worker-graph",
+		Short: "Prints the dependency graph of the workers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			c := dig.New()
+			err := provide(ctx, c)
+			if err != nil {
+				return errors.Wrap(err, "provide workers")
+			}
+
+			nodes, err := runutil.DescribeProvidedWorkers(c)
+			if err != nil {
+				return errors.Wrap(err, "describe workers")
+			}
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "dot":
+				_, err = fmt.Fprint(out, runutil.WorkerGraphDOT(nodes))
+				return errors.WithStack(err)
+			case "json":
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "    ")
+				return errors.WithStack(enc.Encode(nodes))
+			default:
+				return errors.Errorf("unsupported worker graph format %q", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&format, "format", "json",
+		`Output format of the graph. One of "json" or "dot".`)
+
+	return cmd
+}
@@ -0,0 +1,97 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/dig"
+)
+
+type workerGraphTestDB struct {
+	runutil.ReadySignal
+}
+
+func (db *workerGraphTestDB) Workers() []runutil.Worker {
+	return []runutil.Worker{runutil.DeclarativeWorker{
+		Name:   "migrate",
+		Worker: runutil.WorkerFunc(func(ctx context.Context) error { return nil }),
+	}}
+}
+
+type workerGraphTestSync struct {
+	DB *workerGraphTestDB
+}
+
+func (s *workerGraphTestSync) Workers() []runutil.Worker {
+	return []runutil.Worker{runutil.DeclarativeWorker{
+		Name:   "sync",
+		Worker: runutil.WorkerFunc(func(ctx context.Context) error { return nil }),
+	}}
+}
+
+func provideWorkerGraphTest(ctx context.Context, c *dig.Container) error {
+	return errors.Join(
+		c.Provide(func() *workerGraphTestDB { return new(workerGraphTestDB) }),
+		runutil.ProvideWorker(c, func(db *workerGraphTestDB) *workerGraphTestDB { return db }),
+		runutil.ProvideWorker(c, func(db *workerGraphTestDB) *workerGraphTestSync {
+			return &workerGraphTestSync{DB: db}
+		}),
+	)
+}
+
+func executeWorkerGraphCommand(t *testing.T, provide func(context.Context, *dig.Container) error, args ...string) (string, error) {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	cmd := New("graphtest", "test application", WithWorkerGraphCommand(provide))
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs(append([]string{"worker-graph"}, args...))
+
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestWorkerGraphCommand(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		out, err := executeWorkerGraphCommand(t, provideWorkerGraphTest)
+		require.NoError(t, err)
+
+		var graph []runutil.WorkerNode
+		require.NoError(t, json.Unmarshal([]byte(out), &graph))
+		require.Len(t, graph, 2)
+
+		nodes := map[string]runutil.WorkerNode{}
+		for _, n := range graph {
+			nodes[n.Name] = n
+		}
+		assert.True(t, nodes["cmdutil/workerGraphTestDB"].Readier)
+		assert.Equal(t, []string{"cmdutil/workerGraphTestDB"}, nodes["cmdutil/workerGraphTestSync"].DependsOn)
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		out, err := executeWorkerGraphCommand(t, provideWorkerGraphTest, "--format", "dot")
+		require.NoError(t, err)
+		assert.Contains(t, out, "digraph workers {")
+		assert.Contains(t, out, "shape=box")
+		assert.Contains(t, out, `"cmdutil/workerGraphTestSync" -> "cmdutil/workerGraphTestDB";`)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := executeWorkerGraphCommand(t, provideWorkerGraphTest, "--format", "svg")
+		assert.EqualError(t, err, `unsupported worker graph format "svg"`)
+	})
+
+	t.Run("provide error", func(t *testing.T) {
+		_, err := executeWorkerGraphCommand(t, func(context.Context, *dig.Container) error {
+			return errors.New("no database")
+		})
+		assert.EqualError(t, err, "provide workers: no database")
+	})
+}
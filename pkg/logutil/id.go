@@ -2,11 +2,8 @@ package logutil
 
 import (
 	"math/rand"
-	"time"
 )
 
-var random = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 const idAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
 func randomString(l int) string {
@@ -16,7 +13,9 @@ func randomString(l int) string {
 	)
 
 	for i := range b {
-		b[i] = idAlphabet[random.Intn(max)]
+		// The global source is safe for concurrent use, unlike a
+		// rand.Rand.
+		b[i] = idAlphabet[rand.Intn(max)]
 	}
 
 	return string(b)
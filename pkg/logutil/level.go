@@ -0,0 +1,144 @@
+package logutil
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelAll can be used as level of handlers that are wrapped with
+// [NewLevelHandler], because the filtering is done by the level handler.
+const LevelAll = slog.Level(math.MinInt)
+
+// level is the global log level. It defaults to info.
+var level = new(slog.LevelVar)
+
+// subsystemLevels contains the log levels that override the level for a
+// subsystem and its children. It is replaced on each change, so the handlers
+// can read it without locking.
+var subsystemLevels struct {
+	mu     sync.Mutex
+	levels atomic.Pointer[map[string]slog.Level]
+}
+
+// Level returns the current global log level.
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the global log level at runtime.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// SubsystemLevels returns the log levels that were set with
+// [SetSubsystemLevel].
+func SubsystemLevels() map[string]slog.Level {
+	levels := subsystemLevels.levels.Load()
+	if levels == nil {
+		return map[string]slog.Level{}
+	}
+	return maps.Clone(*levels)
+}
+
+// SetSubsystemLevel changes the log level of a subsystem and all its children
+// at runtime, regardless of the global log level. The subsystem is the path
+// returned by [GetSubsystem], eg "/admin-api". It only affects loggers with a
+// handler from [NewLevelHandler].
+func SetSubsystemLevel(subsystem string, l slog.Level) {
+	updateSubsystemLevels(func(levels map[string]slog.Level) {
+		levels[normalizeSubsystem(subsystem)] = l
+	})
+}
+
+// ResetSubsystemLevel removes the log level of a subsystem, so it uses the
+// global log level again.
+func ResetSubsystemLevel(subsystem string) {
+	updateSubsystemLevels(func(levels map[string]slog.Level) {
+		delete(levels, normalizeSubsystem(subsystem))
+	})
+}
+
+func updateSubsystemLevels(fn func(map[string]slog.Level)) {
+	subsystemLevels.mu.Lock()
+	defer subsystemLevels.mu.Unlock()
+
+	levels := SubsystemLevels()
+	fn(levels)
+	subsystemLevels.levels.Store(&levels)
+}
+
+func normalizeSubsystem(subsystem string) string {
+	return "/" + strings.Trim(subsystem, "/")
+}
+
+// subsystemLevel returns the level for the subsystem. The level of the closest
+// parent applies, if the subsystem has no own level.
+func subsystemLevel(subsystem string) slog.Level {
+	levels := subsystemLevels.levels.Load()
+	if levels == nil || len(*levels) == 0 || subsystem == "" {
+		return level.Level()
+	}
+
+	for s := subsystem; ; {
+		l, ok := (*levels)[s]
+		if ok {
+			return l
+		}
+
+		i := strings.LastIndex(s, "/")
+		if i <= 0 {
+			break
+		}
+		s = s[:i]
+	}
+
+	if l, ok := (*levels)["/"]; ok {
+		return l
+	}
+
+	return level.Level()
+}
+
+// levelHandler filters the log records by the level of their subsystem. The
+// subsystem is taken from the "subsystem" attribute, that gets set by
+// [Start].
+type levelHandler struct {
+	next      slog.Handler
+	subsystem string
+}
+
+// NewLevelHandler wraps the handler, so it only handles records that are
+// enabled by the global level (see [SetLevel]) or the level of their subsystem
+// (see [SetSubsystemLevel]). The wrapped handler should accept all levels, eg
+// by using [LevelAll].
+func NewLevelHandler(next slog.Handler) slog.Handler {
+	return &levelHandler{next: next}
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= subsystemLevel(h.subsystem) && h.next.Enabled(ctx, l)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	subsystem := h.subsystem
+	for _, a := range attrs {
+		if a.Key == "subsystem" {
+			subsystem = a.Value.String()
+		}
+	}
+
+	return &levelHandler{next: h.next.WithAttrs(attrs), subsystem: subsystem}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{next: h.next.WithGroup(name), subsystem: h.subsystem}
+}
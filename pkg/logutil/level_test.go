@@ -0,0 +1,130 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resetLevels restores the global and subsystem levels after the test.
+func resetLevels(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		SetLevel(slog.LevelInfo)
+		for subsystem := range SubsystemLevels() {
+			ResetSubsystemLevel(subsystem)
+		}
+	})
+}
+
+func TestSubsystemLevel(t *testing.T) {
+	cases := []struct {
+		name      string
+		levels    map[string]slog.Level
+		subsystem string
+		want      slog.Level
+	}{
+		{
+			name:      "global",
+			subsystem: "/worker",
+			want:      slog.LevelWarn,
+		},
+		{
+			name:      "no subsystem",
+			levels:    map[string]slog.Level{"/worker": slog.LevelDebug},
+			subsystem: "",
+			want:      slog.LevelWarn,
+		},
+		{
+			name:      "own level",
+			levels:    map[string]slog.Level{"/worker": slog.LevelDebug},
+			subsystem: "/worker",
+			want:      slog.LevelDebug,
+		},
+		{
+			name:      "inherited from parent",
+			levels:    map[string]slog.Level{"/worker": slog.LevelDebug},
+			subsystem: "/worker/job/run",
+			want:      slog.LevelDebug,
+		},
+		{
+			name: "closest parent wins",
+			levels: map[string]slog.Level{
+				"/worker":     slog.LevelDebug,
+				"/worker/job": slog.LevelError,
+			},
+			subsystem: "/worker/job/run",
+			want:      slog.LevelError,
+		},
+		{
+			name:      "no prefix match",
+			levels:    map[string]slog.Level{"/worker": slog.LevelDebug},
+			subsystem: "/workers",
+			want:      slog.LevelWarn,
+		},
+		{
+			name:      "root",
+			levels:    map[string]slog.Level{"/": slog.LevelError},
+			subsystem: "/worker",
+			want:      slog.LevelError,
+		},
+		{
+			name:      "normalized",
+			levels:    map[string]slog.Level{"worker/": slog.LevelDebug},
+			subsystem: "/worker/job",
+			want:      slog.LevelDebug,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetLevels(t)
+
+			SetLevel(slog.LevelWarn)
+			for subsystem, l := range tc.levels {
+				SetSubsystemLevel(subsystem, l)
+			}
+
+			assert.Equal(t, tc.want, subsystemLevel(tc.subsystem))
+		})
+	}
+}
+
+func TestResetSubsystemLevel(t *testing.T) {
+	resetLevels(t)
+
+	SetSubsystemLevel("/worker", slog.LevelDebug)
+	assert.Equal(t, map[string]slog.Level{"/worker": slog.LevelDebug}, SubsystemLevels())
+
+	ResetSubsystemLevel("worker")
+	assert.Empty(t, SubsystemLevels())
+	assert.Equal(t, slog.LevelInfo, subsystemLevel("/worker"))
+}
+
+func TestLevelHandler(t *testing.T) {
+	resetLevels(t)
+
+	buf := new(bytes.Buffer)
+	logger := slog.New(NewLevelHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: LevelAll,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	SetSubsystemLevel("/worker", slog.LevelDebug)
+
+	ctx := context.Background()
+	logger.DebugContext(ctx, "dropped")
+	logger.With("subsystem", "/worker/job").WithGroup("job").DebugContext(ctx, "kept", "n", 1)
+	logger.With("subsystem", "/other").DebugContext(ctx, "dropped")
+
+	assert.Equal(t, "level=DEBUG msg=kept subsystem=/worker/job job.n=1\n", buf.String())
+}
@@ -11,6 +11,7 @@
 //   - Transaction Wrappers: Reusable transaction and connection hijacking utilities
 //   - URI Construction: Helper functions for database URI manipulation
 //   - SQLC Templates: Standard configuration templates for consistent project setup
+//   - Distributed Locks: Postgres based runutil.Locker for services without Redis
 //
 // # Quick Start
 //
@@ -107,6 +108,17 @@
 //	dedicatedQueries := sqlc.New(conn)
 //	// Use dedicatedQueries with exclusive connection
 //
+// # Distributed Locks
+//
+// [NewLocker] implements runutil.Locker with a lease table in Postgres, so
+// [NewDistributedRepeat] can coordinate repeats across replicas of services
+// that do not have a Redis server:
+//
+//	worker := pgutil.NewDistributedRepeat(pool, "cleanup", time.Hour, job)
+//
+// The table gets created on first use. See [LockTableSchema] for adding it to
+// the migrations instead.
+//
 // # Configuration Templates
 //
 // The package provides standard SQLC configuration templates. Copy the template to your project:
@@ -0,0 +1,209 @@
+package pgutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+)
+
+// LockTable is the name of the table that is used by [NewLocker] to store the
+// leases. See [LockTableSchema].
+const LockTable = "runutil_locks"
+
+// LockTableSchema creates the table of [NewLocker]. The locker executes it
+// within the search path of the connection on first use, if the table or its
+// fencing_token column does not exist yet. Services whose database user must
+// not create tables can copy the statements into a migration instead (see
+// [Migrate]). The rows are never deleted, so the fencing tokens keep
+// increasing.
+//
+// The ALTER TABLE statement migrates tables that were created before the
+// locker supported fencing tokens. Their existing locks start with a fencing
+// token of 1.
+const LockTableSchema = `CREATE TABLE IF NOT EXISTS ` + LockTable + ` (
+	name          TEXT PRIMARY KEY,
+	holder        TEXT NOT NULL,
+	expires_at    TIMESTAMPTZ NOT NULL,
+	fencing_token BIGINT NOT NULL DEFAULT 1
+);
+ALTER TABLE ` + LockTable + ` ADD COLUMN IF NOT EXISTS fencing_token BIGINT NOT NULL DEFAULT 1;`
+
+// NewDistributedRepeat is the same as runutil.NewDistributedRepeat, but uses
+// Postgres instead of Redis for coordinating the repeats. This is useful for
+// services that do not have a Redis server. See [NewLocker].
+func NewDistributedRepeat(pool *pgxpool.Pool, name string, cooldown time.Duration, job runutil.Job, opts ...runutil.DistributedRepeatOption) runutil.Worker {
+	return runutil.NewDistributedRepeatWithLocker(NewLocker(pool, name), cooldown, job, opts...)
+}
+
+type locker struct {
+	pool  *pgxpool.Pool
+	name  string
+	token string
+	fence atomic.Int64
+
+	setupMu   sync.Mutex
+	setupDone bool
+}
+
+// NewLocker creates a runutil.Locker that stores its lease in a Postgres table
+// (see [LockTableSchema]). It implements runutil.FencingLocker. The lock is
+// only refreshed and released by the Locker that holds it.
+//
+// The lock is implemented as a lease table rather than with a session-level
+// advisory lock, because the lease must be able to outlive a job run (eg to
+// enforce the cooldown of [NewDistributedRepeat]) and must not depend
+// on a single connection staying open. Like the Redis lock, it does not have
+// any correctness guarantees.
+func NewLocker(pool *pgxpool.Pool, name string) runutil.Locker {
+	return &locker{
+		pool:  pool,
+		name:  name,
+		token: runutil.NewLockToken(),
+	}
+}
+
+// setup creates or migrates the lock table, if it was not done yet by this
+// Locker and the table is not up to date already.
+func (l *locker) setup(ctx context.Context) error {
+	l.setupMu.Lock()
+	defer l.setupMu.Unlock()
+
+	if l.setupDone {
+		return nil
+	}
+
+	// The table might be created by a migration and the user might not be
+	// allowed to create or alter tables, which would also fail the IF NOT
+	// EXISTS statements.
+	var exists bool
+	err := l.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_attribute
+			WHERE attrelid = to_regclass($1)
+				AND attname = 'fencing_token'
+				AND NOT attisdropped
+		)`,
+		LockTable,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check lock table: %w", err)
+	}
+	if exists {
+		l.setupDone = true
+		return nil
+	}
+
+	tx, err := l.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Concurrent IF NOT EXISTS statements might fail with a unique
+	// violation, therefore the setup is serialized across replicas.
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, LockTable)
+	if err != nil {
+		return fmt.Errorf("acquire advisory lock: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, LockTableSchema)
+	if err != nil {
+		return fmt.Errorf("create or migrate lock table: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	l.setupDone = true
+	return nil
+}
+
+func (l *locker) TryLock(ctx context.Context, ttl time.Duration) (bool, error) {
+	err := l.setup(ctx)
+	if err != nil {
+		return false, fmt.Errorf("setup lock table: %w", err)
+	}
+
+	// The lock row is never deleted, so the fencing token keeps increasing.
+	var fence int64
+	err = l.pool.QueryRow(ctx, `
+		INSERT INTO `+LockTable+` (name, holder, expires_at)
+		VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+			SET holder = excluded.holder,
+				expires_at = excluded.expires_at,
+				fencing_token = `+LockTable+`.fencing_token + 1
+			WHERE `+LockTable+`.expires_at <= now()
+		RETURNING fencing_token`,
+		l.name, l.token, ttl.Milliseconds(),
+	).Scan(&fence)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("acquire lock %#v: %w", l.name, err)
+	}
+
+	l.fence.Store(fence)
+	return true, nil
+}
+
+func (l *locker) FencingToken() int64 {
+	return l.fence.Load()
+}
+
+func (l *locker) Refresh(ctx context.Context, ttl time.Duration) (bool, error) {
+	tag, err := l.pool.Exec(ctx, `
+		UPDATE `+LockTable+`
+		SET expires_at = now() + $3 * interval '1 millisecond'
+		WHERE name = $1 AND holder = $2 AND expires_at > now()`,
+		l.name, l.token, ttl.Milliseconds(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("refresh lock %#v: %w", l.name, err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (l *locker) Unlock(ctx context.Context) error {
+	// The row is kept to preserve the fencing token.
+	_, err := l.pool.Exec(ctx, `
+		UPDATE `+LockTable+`
+		SET expires_at = now()
+		WHERE name = $1 AND holder = $2`,
+		l.name, l.token,
+	)
+	if err != nil {
+		return fmt.Errorf("unlock %#v: %w", l.name, err)
+	}
+
+	return nil
+}
+
+func (l *locker) TTL(ctx context.Context) (time.Duration, error) {
+	var ms float64
+	err := l.pool.QueryRow(ctx, `
+		SELECT GREATEST(EXTRACT(EPOCH FROM expires_at - now()) * 1000, 0)
+		FROM `+LockTable+`
+		WHERE name = $1`,
+		l.name,
+	).Scan(&ms)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get ttl %#v for lock: %w", l.name, err)
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
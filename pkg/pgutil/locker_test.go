@@ -0,0 +1,196 @@
+package pgutil_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/digutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/pgutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPool connects to the database given by PGUTIL_TEST_URI and uses a
+// fresh schema, that gets dropped after the test. The test gets skipped, if
+// the variable is not set.
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	uri := os.Getenv("PGUTIL_TEST_URI")
+	if uri == "" {
+		t.Skip("PGUTIL_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("pgutil_test_%d", time.Now().UnixNano())
+
+	conn, err := pgx.Connect(ctx, uri)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(ctx, uri)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+		require.NoError(t, err)
+	})
+
+	pool, err := pgutil.NewPool(ctx, pgutil.URI(uri), pgutil.Schema(schema), digutil.Optional[pgutil.EnableTracing]{})
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+
+	a := pgutil.NewLocker(pool, "test").(runutil.FencingLocker)
+	b := pgutil.NewLocker(pool, "test").(runutil.FencingLocker)
+
+	// acquire
+	ok, err := a.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	first := a.FencingToken()
+
+	ok, err = b.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok, "the lock is held by a")
+
+	ttl, err := b.TTL(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(5*time.Second))
+
+	// refresh
+	ok, err = a.Refresh(ctx, 2*time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = b.Refresh(ctx, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok, "b does not hold the lock")
+
+	// lost lock
+	ok, err = a.Refresh(ctx, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+	time.Sleep(200 * time.Millisecond)
+
+	ok, err = b.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok, "the lease of a expired")
+
+	ok, err = a.Refresh(ctx, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok, "a lost the lock")
+
+	// fencing token
+	assert.Greater(t, b.FencingToken(), first)
+
+	require.NoError(t, a.Unlock(ctx))
+	ttl, err = b.TTL(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, ttl, "a must not release the lock of b")
+
+	require.NoError(t, b.Unlock(ctx))
+	ttl, err = b.TTL(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	ok, err = a.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Greater(t, a.FencingToken(), b.FencingToken())
+}
+
+func TestLockerWithMigratedTable(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+
+	_, err := pool.Exec(ctx, pgutil.LockTableSchema)
+	require.NoError(t, err)
+
+	locker := pgutil.NewLocker(pool, "test")
+	ok, err := locker.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestLockerWithOldTable(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+
+	// The table as created by the locker before it supported fencing tokens.
+	_, err := pool.Exec(ctx, `
+		CREATE TABLE `+pgutil.LockTable+` (
+			name       TEXT PRIMARY KEY,
+			holder     TEXT NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		)`)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO `+pgutil.LockTable+` (name, holder, expires_at)
+		VALUES ('test', 'old-holder', now() - interval '1 minute')`)
+	require.NoError(t, err)
+
+	locker := pgutil.NewLocker(pool, "test").(runutil.FencingLocker)
+	ok, err := locker.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(2), locker.FencingToken())
+}
+
+func TestDistributedRepeat(t *testing.T) {
+	pool := newTestPool(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		tokens []int64
+	)
+	job := runutil.JobFunc(func(ctx context.Context) error {
+		token, _ := runutil.FencingToken(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		tokens = append(tokens, token)
+		return nil
+	})
+
+	done := make(chan error, 2)
+	for range 2 {
+		go func() { done <- pgutil.NewDistributedRepeat(pool, "test", time.Hour, job).Run(ctx) }()
+	}
+
+	runs := func() []int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(tokens)
+	}
+
+	require.Eventually(t, func() bool { return len(runs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, []int64{1}, runs(), "the job must run only once per cooldown")
+
+	cancel()
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+}
@@ -1,8 +1,9 @@
 package runutil
 
 import (
+	"errors"
 	"math"
-	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -33,17 +34,21 @@ type ExponentialBackoff struct {
 	Initial          time.Duration
 	Max              time.Duration
 	JitterProportion float64
+
+	// Rand is the source for the jitter. Defaults to the global source of
+	// math/rand. Set it to get reproducible durations in tests.
+	Rand RandSource
 }
 
 func (b ExponentialBackoff) Duration(attempt int) time.Duration {
-	if attempt == 0 {
+	if attempt == 0 || b.Initial <= 0 {
 		return time.Duration(0)
 	}
 
 	var (
 		maxWait   = math.Pow(2., float64(attempt-1))
 		minWait   = maxWait * (1. - b.JitterProportion)
-		jitter    = maxWait * b.JitterProportion * rand.Float64()
+		jitter    = maxWait * b.JitterProportion * randOrGlobal(b.Rand).Float64()
 		totalWait = minWait + jitter
 	)
 
@@ -54,3 +59,190 @@ func (b ExponentialBackoff) Duration(attempt int) time.Duration {
 
 	return time.Duration(float64(b.Initial) * totalWait)
 }
+
+// BackoffStop is returned by a [Backoff] to signal that there should be no
+// further attempt. [RetryJob] and [Retry] return [ErrRetriesExhausted] in this
+// case. See [BackoffWithMaxAttempts].
+const BackoffStop time.Duration = -1
+
+// FullJitterBackoff is an exponential backoff, that waits a random duration
+// between zero and the exponential value. It spreads retries of many clients
+// better than [ExponentialBackoff], but some retries happen almost
+// immediately. See "Full Jitter" in
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type FullJitterBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+
+	// Rand is the source for the jitter. Defaults to the global source of
+	// math/rand.
+	Rand RandSource
+}
+
+func (b FullJitterBackoff) Duration(attempt int) time.Duration {
+	// Without Initial the factor below would be NaN.
+	if attempt == 0 || b.Initial <= 0 {
+		return 0
+	}
+
+	// Like in ExponentialBackoff, the min() must happen before multiplying to
+	// avoid overflows.
+	factor := min(math.Pow(2., float64(attempt-1)), float64(b.Max)/float64(b.Initial))
+	ceiling := time.Duration(float64(b.Initial) * factor)
+
+	return time.Duration(randOrGlobal(b.Rand).Int63n(int64(ceiling) + 1))
+}
+
+// DecorrelatedJitterBackoff waits a random duration between Initial and three
+// times the previous duration, but not longer than Max. See "Decorrelated
+// Jitter" in
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+//
+// Since the duration depends on the previous one, the backoff is stateful and
+// must be used as pointer. The state gets reset with attempt 1, therefore a
+// single instance must not be shared between concurrent retry loops.
+type DecorrelatedJitterBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+
+	// Rand is the source for the jitter. Defaults to the global source of
+	// math/rand.
+	Rand RandSource
+
+	mu   sync.Mutex
+	prev time.Duration
+}
+
+func (b *DecorrelatedJitterBackoff) Duration(attempt int) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if attempt == 1 || b.prev < b.Initial {
+		b.prev = b.Initial
+	}
+
+	upper := min(b.prev*3, b.Max)
+	if upper < b.prev {
+		// overflow
+		upper = b.Max
+	}
+
+	wait := b.Initial
+	if upper > b.Initial {
+		wait += time.Duration(randOrGlobal(b.Rand).Int63n(int64(upper - b.Initial)))
+	}
+
+	b.prev = min(wait, b.Max)
+	return b.prev
+}
+
+// FibonacciBackoff multiplies Initial with the Fibonacci sequence (1, 1, 2,
+// 3, 5, 8, ...), but never waits longer than Max. It grows slower than
+// [ExponentialBackoff].
+type FibonacciBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+func (b FibonacciBackoff) Duration(attempt int) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+
+	limit := int64(b.Max / max(b.Initial, 1))
+
+	var a, c int64 = 0, 1
+	for i := 1; i < attempt && c <= limit; i++ {
+		a, c = c, a+c
+	}
+
+	return min(time.Duration(c)*b.Initial, b.Max)
+}
+
+// LinearBackoff starts with Initial and adds Step for each further attempt,
+// but never waits longer than Max.
+type LinearBackoff struct {
+	Initial time.Duration
+	Step    time.Duration
+	Max     time.Duration
+}
+
+func (b LinearBackoff) Duration(attempt int) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+
+	if b.Step > 0 && attempt-1 > int((b.Max-b.Initial)/b.Step) {
+		return b.Max
+	}
+
+	return min(b.Initial+time.Duration(attempt-1)*b.Step, b.Max)
+}
+
+// CappedBackoff limits the durations of another [Backoff] to Max.
+type CappedBackoff struct {
+	Backoff Backoff
+	Max     time.Duration
+}
+
+func (b CappedBackoff) Duration(attempt int) time.Duration {
+	d := b.Backoff.Duration(attempt)
+	if d == BackoffStop {
+		return d
+	}
+
+	return min(d, b.Max)
+}
+
+// BackoffWithMaxAttempts returns [BackoffStop] after MaxAttempts attempts and
+// the durations of the wrapped [Backoff] before. The first attempt is always
+// allowed, even if MaxAttempts is not positive.
+type BackoffWithMaxAttempts struct {
+	Backoff     Backoff
+	MaxAttempts int
+}
+
+func (b BackoffWithMaxAttempts) Duration(attempt int) time.Duration {
+	if attempt > 0 && attempt >= b.MaxAttempts {
+		return BackoffStop
+	}
+
+	return b.Backoff.Duration(attempt)
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string             { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error             { return e.err }
+func (e *retryAfterError) RetryAfter() time.Duration { return e.delay }
+
+// RetryAfter attaches a delay to an error, that overrides the duration of the
+// [Backoff] in [RetryJob] and [Retry]. This is useful for a delay provided by
+// a server, like the HTTP Retry-After header. It returns nil, if err is nil.
+//
+// Any error that implements a RetryAfter() time.Duration method is honoured,
+// like [CircuitOpenError]. Delays that are not positive are ignored.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	return &retryAfterError{err: err, delay: delay}
+}
+
+func retryAfterOf(err error) (time.Duration, bool) {
+	var ra interface{ RetryAfter() time.Duration }
+	if !errors.As(err, &ra) {
+		return 0, false
+	}
+
+	d := ra.RetryAfter()
+	return d, d > 0
+}
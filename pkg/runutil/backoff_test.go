@@ -12,6 +12,12 @@ import (
 func TestBackoffTypes(t *testing.T) {
 	assert.Implements(t, new(Backoff), ExponentialBackoff{})
 	assert.Implements(t, new(Backoff), StaticBackoff{})
+	assert.Implements(t, new(Backoff), FullJitterBackoff{})
+	assert.Implements(t, new(Backoff), &DecorrelatedJitterBackoff{})
+	assert.Implements(t, new(Backoff), FibonacciBackoff{})
+	assert.Implements(t, new(Backoff), LinearBackoff{})
+	assert.Implements(t, new(Backoff), CappedBackoff{})
+	assert.Implements(t, new(Backoff), BackoffWithMaxAttempts{})
 }
 
 func TestStaticBackoff(t *testing.T) {
@@ -105,3 +111,114 @@ func TestExponentialBackoffWithHighAttempts(t *testing.T) {
 		})
 	}
 }
+
+func TestFullJitterBackoff(t *testing.T) {
+	bo := FullJitterBackoff{Initial: time.Second, Max: time.Minute}
+	ceilings := []int{0, 1, 2, 4, 8, 16, 32, 60, 60, 60}
+
+	for range 100 {
+		for attempt, ceiling := range ceilings {
+			have := bo.Duration(attempt)
+			assert.GreaterOrEqual(t, have, time.Duration(0), "attempt #%d", attempt)
+			assert.LessOrEqual(t, have, time.Duration(ceiling)*time.Second, "attempt #%d", attempt)
+		}
+	}
+
+	assert.LessOrEqual(t, bo.Duration(1e8), time.Minute)
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	bo := &DecorrelatedJitterBackoff{Initial: time.Second, Max: time.Minute}
+	assert.Equal(t, time.Duration(0), bo.Duration(0))
+
+	for range 10 {
+		prev := time.Second
+		for attempt := 1; attempt < 100; attempt++ {
+			have := bo.Duration(attempt)
+			assert.GreaterOrEqual(t, have, time.Second, "attempt #%d", attempt)
+			assert.LessOrEqual(t, have, min(3*prev, time.Minute), "attempt #%d", attempt)
+			prev = have
+		}
+	}
+}
+
+func TestFibonacciBackoff(t *testing.T) {
+	bo := FibonacciBackoff{Initial: time.Second, Max: time.Minute}
+	want := []int{0, 1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 60, 60}
+
+	for attempt, expected := range want {
+		assert.Equal(t, time.Duration(expected)*time.Second, bo.Duration(attempt), "attempt #%d", attempt)
+	}
+
+	assert.Equal(t, time.Minute, bo.Duration(1e8))
+}
+
+func TestLinearBackoff(t *testing.T) {
+	bo := LinearBackoff{Initial: time.Second, Step: 2 * time.Second, Max: 10 * time.Second}
+	want := []int{0, 1, 3, 5, 7, 9, 10, 10}
+
+	for attempt, expected := range want {
+		assert.Equal(t, time.Duration(expected)*time.Second, bo.Duration(attempt), "attempt #%d", attempt)
+	}
+
+	assert.Equal(t, 10*time.Second, bo.Duration(1e8))
+}
+
+func TestCappedBackoff(t *testing.T) {
+	bo := CappedBackoff{
+		Backoff: ExponentialBackoff{Initial: time.Second, Max: time.Hour},
+		Max:     5 * time.Second,
+	}
+	want := []int{0, 1, 2, 4, 5, 5}
+
+	for attempt, expected := range want {
+		assert.Equal(t, time.Duration(expected)*time.Second, bo.Duration(attempt), "attempt #%d", attempt)
+	}
+}
+
+func TestBackoffWithMaxAttempts(t *testing.T) {
+	bo := BackoffWithMaxAttempts{
+		Backoff:     StaticBackoff{Sleep: time.Second},
+		MaxAttempts: 3,
+	}
+
+	assert.Equal(t, time.Duration(0), bo.Duration(0))
+	assert.Equal(t, time.Second, bo.Duration(1))
+	assert.Equal(t, time.Second, bo.Duration(2))
+	assert.Equal(t, BackoffStop, bo.Duration(3))
+	assert.Equal(t, BackoffStop, CappedBackoff{Backoff: bo, Max: time.Millisecond}.Duration(4))
+}
+
+func TestBackoffWithoutMaxAttempts(t *testing.T) {
+	for _, maxAttempts := range []int{-1, 0, 1} {
+		t.Run(fmt.Sprint(maxAttempts), func(t *testing.T) {
+			bo := BackoffWithMaxAttempts{
+				Backoff:     StaticBackoff{Sleep: time.Second},
+				MaxAttempts: maxAttempts,
+			}
+
+			assert.Equal(t, time.Duration(0), bo.Duration(0))
+			assert.Equal(t, BackoffStop, bo.Duration(1))
+		})
+	}
+}
+
+func TestBackoffWithoutInitial(t *testing.T) {
+	cases := []struct {
+		name string
+		bo   Backoff
+	}{
+		{name: "exponential", bo: ExponentialBackoff{}},
+		{name: "exponential with max", bo: ExponentialBackoff{Max: time.Second, JitterProportion: 0.5}},
+		{name: "full jitter", bo: FullJitterBackoff{}},
+		{name: "full jitter with max", bo: FullJitterBackoff{Max: time.Second}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for attempt := range 5 {
+				assert.Equal(t, time.Duration(0), tc.bo.Duration(attempt), "attempt #%d", attempt)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+const promBatcherSubsystem = "batcher"
+
+var instBatcherItemsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: PromNamespace,
+	Subsystem: promBatcherSubsystem,
+	Name:      "items_total",
+}, []string{"batcher", "result"})
+
+// ErrBatcherFull is returned by [Batcher.Add], when the item was dropped
+// because the buffer is full.
+var ErrBatcherFull = errors.New("batcher buffer is full")
+
+// BatchSink receives the batches of a [Batcher]. The items slice gets reused
+// after Flush returned, therefore the sink must not retain it.
+type BatchSink[T any] interface {
+	Flush(ctx context.Context, items []T) error
+}
+
+// BatchSinkFunc is a function that implements [BatchSink].
+type BatchSinkFunc[T any] func(ctx context.Context, items []T) error
+
+func (f BatchSinkFunc[T]) Flush(ctx context.Context, items []T) error {
+	return f(ctx, items)
+}
+
+// BackpressureMode defines what [Batcher.Add] does, when the buffer is full.
+type BackpressureMode int
+
+const (
+	// BackpressureDrop drops the item, so producers never get slowed down by
+	// a slow sink.
+	BackpressureDrop BackpressureMode = iota
+
+	// BackpressureBlock blocks until there is space in the buffer or the
+	// context gets cancelled.
+	BackpressureBlock
+
+	// BackpressureBlockTimeout blocks like BackpressureBlock, but drops the
+	// item after the timeout from [WithBlockTimeout].
+	BackpressureBlockTimeout
+)
+
+type batcherConfig struct {
+	name         string
+	bufferSize   int
+	backpressure BackpressureMode
+	blockTimeout time.Duration
+	flushTimeout time.Duration
+	backoff      Backoff
+	retryOpts    []RetryOption
+}
+
+type BatcherOption func(*batcherConfig)
+
+// WithBatcherName sets the name that is used as label for the Prometheus
+// metrics and as "batcher" field in the log messages, so it should name the
+// sink (eg "clickhouse"). Defaults to the type name of the items.
+func WithBatcherName(name string) BatcherOption {
+	return func(c *batcherConfig) {
+		c.name = name
+	}
+}
+
+// WithBufferSize sets the number of items that can be queued, before the
+// backpressure applies. Defaults to two batches, so producers can keep adding
+// items while a flush is in flight.
+func WithBufferSize(n int) BatcherOption {
+	return func(c *batcherConfig) {
+		c.bufferSize = n
+	}
+}
+
+// WithBackpressure defines what happens, when the buffer is full. Defaults to
+// [BackpressureDrop].
+func WithBackpressure(mode BackpressureMode) BatcherOption {
+	return func(c *batcherConfig) {
+		c.backpressure = mode
+	}
+}
+
+// WithBlockTimeout sets how long [Batcher.Add] blocks with
+// [BackpressureBlockTimeout]. Defaults to 1s.
+func WithBlockTimeout(d time.Duration) BatcherOption {
+	return func(c *batcherConfig) {
+		c.blockTimeout = d
+	}
+}
+
+// WithFlushTimeout bounds a single flush including its retries. Defaults to
+// 30s.
+func WithFlushTimeout(d time.Duration) BatcherOption {
+	return func(c *batcherConfig) {
+		c.flushTimeout = d
+	}
+}
+
+// WithFlushRetry retries failed flushes with the given backoff, like
+// [RetryJob]. The retries stop at the latest with the flush timeout. By default
+// a failed batch gets dropped immediately.
+func WithFlushRetry(bo Backoff, opts ...RetryOption) BatcherOption {
+	return func(c *batcherConfig) {
+		c.backoff = bo
+		c.retryOpts = opts
+	}
+}
+
+// Batcher buffers items of type T and flushes them to a [BatchSink] in bulk,
+// triggered either by reaching maxSize or by the maxWait interval elapsing.
+// This is useful for sinks like ClickHouse, Postgres COPY, S3 or HTTP bulk
+// APIs. Create it with [NewBatcher].
+//
+// Run is a long-lived loop, so it must not be wrapped in [Repeat].
+//
+// It reports the number of items as Prometheus metrics with the prefix
+// rebuy_go_sdk_batcher. See [WithBatcherName].
+type Batcher[T any] struct {
+	config  batcherConfig
+	sink    BatchSink[T]
+	maxSize int
+	maxWait time.Duration
+
+	items   chan T
+	dropped atomic.Uint64
+}
+
+// NewBatcher creates a [Batcher] that flushes to the given sink. A maxSize
+// below 1 is treated as 1.
+func NewBatcher[T any](sink BatchSink[T], maxSize int, maxWait time.Duration, opts ...BatcherOption) *Batcher[T] {
+	maxSize = max(maxSize, 1)
+
+	c := batcherConfig{
+		name:         typeName(new(T)),
+		bufferSize:   maxSize * 2,
+		blockTimeout: time.Second,
+		flushTimeout: 30 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return &Batcher[T]{
+		config:  c,
+		sink:    sink,
+		maxSize: maxSize,
+		maxWait: maxWait,
+		items:   make(chan T, max(c.bufferSize, 0)),
+	}
+}
+
+// Workers satisfies [WorkerConfiger], so the Batcher can be registered as a
+// worker. It returns itself as a single long-lived worker.
+func (b *Batcher[T]) Workers() []Worker {
+	return []Worker{b}
+}
+
+// Add enqueues an item. When the buffer is full, it behaves according to the
+// [BackpressureMode]. It returns [ErrBatcherFull], if the item was dropped, and
+// the error of the context, if it got cancelled while blocking.
+func (b *Batcher[T]) Add(ctx context.Context, item T) error {
+	select {
+	case b.items <- item:
+		instBatcherItemsTotal.WithLabelValues(b.config.name, "added").Inc()
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+
+	switch b.config.backpressure {
+	case BackpressureBlock:
+	case BackpressureBlockTimeout:
+		timer := ClockFromContext(ctx).NewTimer(b.config.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	default:
+		return b.drop()
+	}
+
+	select {
+	case b.items <- item:
+		instBatcherItemsTotal.WithLabelValues(b.config.name, "added").Inc()
+		return nil
+	case <-timeout:
+		return b.drop()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Batcher[T]) drop() error {
+	b.dropped.Add(1)
+	instBatcherItemsTotal.WithLabelValues(b.config.name, "dropped").Inc()
+	return ErrBatcherFull
+}
+
+// Dropped returns the number of items dropped so far because the buffer was
+// full.
+func (b *Batcher[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Pending returns the number of queued items, that are not yet taken by Run.
+func (b *Batcher[T]) Pending() int {
+	return len(b.items)
+}
+
+// Run consumes the queue until the context is cancelled, flushing the items in
+// batches. On cancellation it drains the already-queued items and flushes a
+// final partial batch before returning. Failed flushes are logged at WARN
+// rather than returned, so a single failed batch does not tear down sibling
+// workers.
+func (b *Batcher[T]) Run(ctx context.Context) error {
+	ticker := ClockFromContext(ctx).NewTicker(b.maxWait)
+	defer ticker.Stop()
+
+	buffer := make([]T, 0, b.maxSize)
+	flush := func() {
+		if len(buffer) == 0 {
+			return
+		}
+
+		b.flush(ctx, buffer)
+		buffer = buffer[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Drain whatever is already queued so the final partial batch is
+			// not lost. The channel is intentionally not closed: Add may still
+			// be called and must not panic.
+			for {
+				select {
+				case item := <-b.items:
+					buffer = append(buffer, item)
+					if len(buffer) >= b.maxSize {
+						flush()
+					}
+				default:
+					flush()
+					if dropped := b.dropped.Load(); dropped > 0 {
+						logutil.Get(ctx).Warn("dropped items due to full buffer",
+							"batcher", b.config.name, "count", dropped)
+					}
+					return nil
+				}
+			}
+		case item := <-b.items:
+			buffer = append(buffer, item)
+			if len(buffer) >= b.maxSize {
+				flush()
+			}
+		case <-ticker.C():
+			flush()
+		}
+	}
+}
+
+func (b *Batcher[T]) flush(ctx context.Context, items []T) {
+	// Detach from ctx cancellation so a flush triggered during shutdown still
+	// completes, while keeping a timeout as a backstop.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), b.config.flushTimeout)
+	defer cancel()
+
+	job := Job(JobFunc(func(ctx context.Context) error {
+		return b.sink.Flush(ctx, items)
+	}))
+
+	if b.config.backoff != nil {
+		job = RetryJob(job, b.config.backoff, b.config.retryOpts...)
+	}
+
+	err := job.RunOnce(ctx)
+	if err != nil {
+		instBatcherItemsTotal.WithLabelValues(b.config.name, "failed").Add(float64(len(items)))
+		logutil.Get(ctx).Warn("failed to flush batch",
+			"batcher", b.config.name, "count", len(items), "error", err)
+		return
+	}
+
+	instBatcherItemsTotal.WithLabelValues(b.config.name, "flushed").Add(float64(len(items)))
+}
@@ -0,0 +1,193 @@
+package runutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSink records all flushed batches. It optionally fails the first
+// flushes.
+type testSink struct {
+	mu       sync.Mutex
+	batches  [][]int
+	attempts int
+	failures int
+}
+
+func (s *testSink) Flush(ctx context.Context, items []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.New("fail")
+	}
+
+	s.batches = append(s.batches, slices.Clone(items))
+	return nil
+}
+
+func (s *testSink) flushed() [][]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.batches)
+}
+
+func runBatcher(t *testing.T, b *Batcher[int]) func() {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	return func() {
+		cancel()
+		require.NoError(t, <-done)
+	}
+}
+
+func TestBatcherSizeAndTimeFlush(t *testing.T) {
+	sink := &testSink{}
+	b := NewBatcher[int](sink, 3, 20*time.Millisecond)
+	stop := runBatcher(t, b)
+	defer stop()
+
+	ctx := context.Background()
+	for i := range 4 {
+		require.NoError(t, b.Add(ctx, i))
+	}
+
+	require.Eventually(t, func() bool { return len(sink.flushed()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, [][]int{{0, 1, 2}, {3}}, sink.flushed())
+}
+
+func TestBatcherDrainOnCancel(t *testing.T) {
+	sink := &testSink{}
+	b := NewBatcher[int](sink, 1000, time.Hour)
+
+	ctx := context.Background()
+	require.NoError(t, b.Add(ctx, 1))
+	require.NoError(t, b.Add(ctx, 2))
+
+	stop := runBatcher(t, b)
+	require.Eventually(t, func() bool { return b.Pending() == 0 }, time.Second, time.Millisecond)
+	stop()
+
+	assert.Equal(t, [][]int{{1, 2}}, sink.flushed())
+}
+
+func TestBatcherBackpressure(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drop", func(t *testing.T) {
+		b := NewBatcher[int](&testSink{}, 2, time.Hour)
+		for i := range 4 {
+			require.NoError(t, b.Add(ctx, i))
+		}
+		require.ErrorIs(t, b.Add(ctx, 4), ErrBatcherFull)
+		assert.Equal(t, uint64(1), b.Dropped())
+	})
+
+	t.Run("block", func(t *testing.T) {
+		b := NewBatcher[int](&testSink{}, 1, time.Hour,
+			WithBufferSize(1), WithBackpressure(BackpressureBlock))
+		require.NoError(t, b.Add(ctx, 1))
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, b.Add(ctx, 2), context.DeadlineExceeded)
+		assert.Equal(t, uint64(0), b.Dropped())
+	})
+
+	t.Run("block-timeout", func(t *testing.T) {
+		b := NewBatcher[int](&testSink{}, 1, time.Hour, WithBufferSize(1),
+			WithBackpressure(BackpressureBlockTimeout), WithBlockTimeout(10*time.Millisecond))
+		require.NoError(t, b.Add(ctx, 1))
+		require.ErrorIs(t, b.Add(ctx, 2), ErrBatcherFull)
+		assert.Equal(t, uint64(1), b.Dropped())
+	})
+
+	t.Run("block-unblocks", func(t *testing.T) {
+		sink := &testSink{}
+		b := NewBatcher[int](sink, 1, time.Hour,
+			WithBufferSize(1), WithBackpressure(BackpressureBlock))
+		require.NoError(t, b.Add(ctx, 1))
+
+		added := make(chan error)
+		go func() { added <- b.Add(ctx, 2) }()
+
+		stop := runBatcher(t, b)
+		require.NoError(t, <-added)
+		require.Eventually(t, func() bool { return len(sink.flushed()) == 2 }, time.Second, time.Millisecond)
+		stop()
+	})
+}
+
+func TestBatcherFlushRetry(t *testing.T) {
+	sink := &testSink{failures: 2}
+	b := NewBatcher[int](sink, 2, time.Hour,
+		WithFlushRetry(StaticBackoff{Sleep: time.Millisecond}))
+	stop := runBatcher(t, b)
+	defer stop()
+
+	ctx := context.Background()
+	require.NoError(t, b.Add(ctx, 1))
+	require.NoError(t, b.Add(ctx, 2))
+
+	require.Eventually(t, func() bool { return len(sink.flushed()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, [][]int{{1, 2}}, sink.flushed())
+}
+
+func TestBatcherFlushFailureDropsBatch(t *testing.T) {
+	sink := &testSink{failures: 1}
+	b := NewBatcher[int](sink, 1, time.Hour)
+	stop := runBatcher(t, b)
+
+	ctx := context.Background()
+	require.NoError(t, b.Add(ctx, 1))
+	require.NoError(t, b.Add(ctx, 2))
+
+	require.Eventually(t, func() bool { return len(sink.flushed()) == 1 }, time.Second, time.Millisecond)
+	stop()
+
+	assert.Equal(t, [][]int{{2}}, sink.flushed())
+}
+
+func TestBatcherLogsName(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	sink := &testSink{failures: 1}
+	b := NewBatcher[int](sink, 1, time.Hour, WithBatcherName("test-sink"))
+
+	ctx, cancel := context.WithCancel(logutil.Start(context.Background(), "test-batcher-logs"))
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	require.NoError(t, b.Add(ctx, 1))
+	require.Eventually(t, func() bool {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return sink.attempts == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "failed to flush batch", entry["msg"])
+	assert.Equal(t, "test-sink", entry["batcher"])
+}
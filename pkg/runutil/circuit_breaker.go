@@ -0,0 +1,397 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+const promCircuitBreakerSubsystem = "circuit_breaker"
+
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+)
+
+var (
+	instCircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: PromNamespace,
+		Subsystem: promCircuitBreakerSubsystem,
+		Name:      "state",
+	}, []string{"breaker", "state"})
+
+	instCircuitBreakerCallsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: PromNamespace,
+		Subsystem: promCircuitBreakerSubsystem,
+		Name:      "calls_total",
+	}, []string{"breaker", "result"})
+)
+
+// ErrCircuitOpen is returned by a [CircuitBreaker] that rejects a call. Use
+// [errors.Is] to check for it, or [errors.As] with [*CircuitOpenError] to get
+// more details.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is the error that is returned by a [CircuitBreaker] that
+// rejects a call.
+type CircuitOpenError struct {
+	Name  string
+	Until time.Time
+
+	clock Clock
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker %s is open until %s", e.Name, e.Until.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// RetryAfter returns the remaining time until the breaker allows calls again.
+// This makes [RetryJob] wait until then, instead of using its backoff.
+func (e *CircuitOpenError) RetryAfter() time.Duration {
+	clock := e.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	return e.Until.Sub(clock.Now())
+}
+
+// CircuitBreaker prevents calls to a failing dependency, to give it time to
+// recover. It has three states:
+//
+//   - closed: All calls pass. The breaker opens, if the number of consecutive
+//     failures or the failure ratio exceed the configured thresholds.
+//   - open: All calls get rejected with [ErrCircuitOpen]. After a cooldown
+//     the breaker switches to half-open.
+//   - half-open: A limited number of probe calls pass. The breaker closes,
+//     if all of them succeed, and opens again on the first failure.
+//
+// The cooldown is calculated with a [Backoff], based on the number of times
+// the breaker opened in a row, so a dependency that keeps failing gets probed
+// less often.
+//
+// State changes are reported to a dedicated health monitor named
+// "circuit-breaker/" followed by the name of the breaker (see [HealthReport])
+// and as Prometheus metrics with the prefix rebuy_go_sdk_circuit_breaker. The
+// monitor is informational, so an open breaker does not fail [HealthReady].
+// The health of the calling worker is not touched, so a closing breaker does
+// not hide failures of the worker.
+type CircuitBreaker struct {
+	name string
+
+	consecutiveThreshold int
+	ratioThreshold       float64
+	ratioMinCalls        int
+	interval             time.Duration
+	halfOpenCalls        int
+	backoff              Backoff
+	clock                Clock
+
+	mu          sync.Mutex
+	state       string
+	calls       int
+	failures    int
+	consecutive int
+	opened      int
+	openUntil   time.Time
+	cooldown    time.Duration
+	windowStart time.Time
+	probes      int
+	probesOK    int
+}
+
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// WithConsecutiveFailures opens the breaker after n consecutive failures.
+// Defaults to 5. Zero disables the check.
+func WithConsecutiveFailures(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.consecutiveThreshold = n
+	}
+}
+
+// WithFailureRatio opens the breaker, if the ratio of failed calls reaches the
+// given ratio (between 0 and 1) within the counting interval, but only after
+// at least minCalls calls. Disabled by default.
+func WithFailureRatio(ratio float64, minCalls int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.ratioThreshold = ratio
+		cb.ratioMinCalls = minCalls
+	}
+}
+
+// WithCountingInterval sets the interval after which the call counters of the
+// closed state get reset. Defaults to one minute.
+func WithCountingInterval(d time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.interval = d
+	}
+}
+
+// WithHalfOpenCalls sets the number of successful probe calls in the half-open
+// state, that are needed to close the breaker again. Defaults to 1.
+func WithHalfOpenCalls(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenCalls = n
+	}
+}
+
+// WithOpenBackoff sets the backoff for the cooldown of the open state. The
+// attempt passed to the backoff is the number of times the breaker opened
+// without closing in between. Defaults to an exponential backoff between 5s and
+// 5m. If the backoff returns [BackoffStop], eg because it is wrapped with
+// [BackoffWithMaxAttempts], the breaker keeps the previous cooldown.
+func WithOpenBackoff(bo Backoff) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.backoff = bo
+	}
+}
+
+// WithCircuitBreakerClock sets the [Clock] of the breaker. The breaker does not
+// use the clock of the context (see [WithClock]), because its state is shared
+// between all calls. Defaults to the real clock.
+func WithCircuitBreakerClock(clock Clock) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.clock = clock
+	}
+}
+
+// NewCircuitBreaker creates a new [CircuitBreaker]. The name is used for
+// metrics and errors.
+func NewCircuitBreaker(name string, opts ...CircuitBreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		name:                 name,
+		consecutiveThreshold: 5,
+		interval:             time.Minute,
+		halfOpenCalls:        1,
+		backoff: ExponentialBackoff{
+			Initial: 5 * time.Second,
+			Max:     5 * time.Minute,
+		},
+		clock: realClock{},
+		state: CircuitStateClosed,
+	}
+
+	for _, o := range opts {
+		o(cb)
+	}
+
+	cb.windowStart = cb.clock.Now()
+
+	for _, state := range []string{CircuitStateClosed, CircuitStateOpen, CircuitStateHalfOpen} {
+		// Register zero values immediately to avoid null values in Prometheus.
+		instCircuitBreakerState.WithLabelValues(name, state).Set(0)
+	}
+	instCircuitBreakerState.WithLabelValues(name, CircuitStateClosed).Set(1)
+
+	return cb
+}
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.advance(cb.clock.Now())
+	return cb.state
+}
+
+// Do executes the function, if the breaker allows it. Otherwise it returns a
+// [*CircuitOpenError] without calling the function. Errors that are caused by
+// the cancellation of the given context do not count as failures. A panic of
+// the function does not count either, but gets propagated.
+func (cb *CircuitBreaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := cb.allow(ctx)
+	if err != nil {
+		instCircuitBreakerCallsTotal.WithLabelValues(cb.name, "rejected").Inc()
+		return err
+	}
+
+	recorded := false
+	defer func() {
+		if !recorded {
+			// fn panicked, but the probe slot needs to be freed again.
+			cb.mu.Lock()
+			defer cb.mu.Unlock()
+			cb.releaseProbe()
+		}
+	}()
+
+	err = fn(ctx)
+	recorded = true
+	cb.record(ctx, err)
+
+	return err
+}
+
+// Job wraps a [Job], so it only runs when the breaker allows it.
+func (cb *CircuitBreaker) Job(job Job) Job {
+	return JobFunc(func(ctx context.Context) error {
+		return cb.Do(ctx, job.RunOnce)
+	})
+}
+
+// Worker wraps a [Worker], so it only starts when the breaker allows it. This
+// is useful in combination with [Retry], which restarts the worker after it
+// failed.
+func (cb *CircuitBreaker) Worker(worker Worker) Worker {
+	return WorkerFunc(func(ctx context.Context) error {
+		return cb.Do(ctx, worker.Run)
+	})
+}
+
+func (cb *CircuitBreaker) allow(ctx context.Context) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.advance(cb.clock.Now())
+
+	switch cb.state {
+	case CircuitStateOpen:
+		return &CircuitOpenError{Name: cb.name, Until: cb.openUntil, clock: cb.clock}
+	case CircuitStateHalfOpen:
+		if cb.probes >= cb.halfOpenCalls {
+			return &CircuitOpenError{Name: cb.name, Until: cb.clock.Now(), clock: cb.clock}
+		}
+		cb.probes++
+	}
+
+	return nil
+}
+
+func (cb *CircuitBreaker) record(ctx context.Context, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err != nil && ctx.Err() != nil {
+		// Cancellations are not the fault of the dependency, but the probe
+		// slot needs to be freed again.
+		cb.releaseProbe()
+		return
+	}
+
+	now := cb.clock.Now()
+	cb.advance(now)
+
+	if err == nil {
+		instCircuitBreakerCallsTotal.WithLabelValues(cb.name, "success").Inc()
+	} else {
+		instCircuitBreakerCallsTotal.WithLabelValues(cb.name, "failure").Inc()
+	}
+
+	switch cb.state {
+	case CircuitStateHalfOpen:
+		if err != nil {
+			cb.open(ctx, now, err)
+			return
+		}
+
+		cb.probesOK++
+		if cb.probesOK >= cb.halfOpenCalls {
+			cb.opened = 0
+			cb.transition(ctx, CircuitStateClosed, nil)
+			cb.resetCounts(now)
+		}
+
+	case CircuitStateClosed:
+		cb.calls++
+		if err == nil {
+			cb.consecutive = 0
+			return
+		}
+
+		cb.failures++
+		cb.consecutive++
+
+		if cb.consecutiveThreshold > 0 && cb.consecutive >= cb.consecutiveThreshold {
+			cb.open(ctx, now, err)
+			return
+		}
+
+		if cb.ratioThreshold > 0 && cb.calls >= cb.ratioMinCalls &&
+			float64(cb.failures)/float64(cb.calls) >= cb.ratioThreshold {
+			cb.open(ctx, now, err)
+			return
+		}
+	}
+}
+
+// advance switches from open to half-open after the cooldown and resets the
+// counters of the closed state after the interval. It must be called with
+// locked mutex.
+func (cb *CircuitBreaker) advance(now time.Time) {
+	switch cb.state {
+	case CircuitStateOpen:
+		if !now.Before(cb.openUntil) {
+			cb.probes = 0
+			cb.probesOK = 0
+			cb.transition(context.Background(), CircuitStateHalfOpen, nil)
+		}
+	case CircuitStateClosed:
+		if cb.interval > 0 && now.Sub(cb.windowStart) >= cb.interval {
+			cb.resetCounts(now)
+		}
+	}
+}
+
+// releaseProbe frees a probe slot of the half-open state for a call that did
+// not finish regularly. It must be called with locked mutex.
+func (cb *CircuitBreaker) releaseProbe() {
+	if cb.state == CircuitStateHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+func (cb *CircuitBreaker) open(ctx context.Context, now time.Time, cause error) {
+	cb.opened++
+
+	// BackoffStop would move openUntil into the past.
+	if d := cb.backoff.Duration(cb.opened); d >= 0 {
+		cb.cooldown = d
+	}
+	cb.openUntil = now.Add(cb.cooldown)
+	cb.transition(ctx, CircuitStateOpen, cause)
+	cb.resetCounts(now)
+}
+
+func (cb *CircuitBreaker) resetCounts(now time.Time) {
+	cb.calls = 0
+	cb.failures = 0
+	cb.consecutive = 0
+	cb.windowStart = now
+}
+
+func (cb *CircuitBreaker) transition(ctx context.Context, state string, cause error) {
+	if cb.state == state {
+		return
+	}
+
+	logutil.Get(ctx).Info("circuit breaker changed state",
+		"breaker", cb.name, "from", cb.state, "to", state, "error", cause)
+
+	instCircuitBreakerState.WithLabelValues(cb.name, cb.state).Set(0)
+	instCircuitBreakerState.WithLabelValues(cb.name, state).Set(1)
+	cb.state = state
+
+	switch state {
+	case CircuitStateOpen:
+		cb.health().Checkpoint(&CircuitOpenError{Name: cb.name, Until: cb.openUntil, clock: cb.clock})
+	case CircuitStateClosed:
+		cb.health().Checkpoint(nil)
+	}
+}
+
+func (cb *CircuitBreaker) health() HealthMonitor {
+	return healthRegistry.getInformational("circuit-breaker/"+cb.name, cb.clock)
+}
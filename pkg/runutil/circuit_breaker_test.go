@@ -0,0 +1,188 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerSuccessResetsConsecutive(t *testing.T) {
+	ctx := context.Background()
+	errFail := errors.New("fail")
+
+	cb := NewCircuitBreaker("test-reset", WithConsecutiveFailures(2))
+
+	for range 5 {
+		_ = cb.Do(ctx, func(context.Context) error { return errFail })
+		_ = cb.Do(ctx, func(context.Context) error { return nil })
+	}
+
+	require.Equal(t, CircuitStateClosed, cb.State())
+}
+
+func TestCircuitBreakerFailureRatio(t *testing.T) {
+	ctx := context.Background()
+	errFail := errors.New("fail")
+
+	cb := NewCircuitBreaker("test-ratio",
+		WithConsecutiveFailures(0),
+		WithFailureRatio(0.5, 4),
+	)
+
+	results := []error{errFail, nil, errFail}
+	for _, r := range results {
+		_ = cb.Do(ctx, func(context.Context) error { return r })
+	}
+	require.Equal(t, CircuitStateClosed, cb.State(), "min calls not reached")
+
+	_ = cb.Do(ctx, func(context.Context) error { return nil })
+	require.Equal(t, CircuitStateClosed, cb.State(), "2 of 4 failed, but last one succeeded")
+
+	_ = cb.Do(ctx, func(context.Context) error { return errFail })
+	require.Equal(t, CircuitStateOpen, cb.State())
+}
+
+func TestCircuitBreakerHalfOpenLimitsProbes(t *testing.T) {
+	ctx := context.Background()
+
+	cb := NewCircuitBreaker("test-probes",
+		WithConsecutiveFailures(1),
+		WithHalfOpenCalls(2),
+		WithOpenBackoff(StaticBackoff{Sleep: 10 * time.Millisecond}),
+	)
+
+	_ = cb.Do(ctx, func(context.Context) error { return errors.New("fail") })
+	time.Sleep(20 * time.Millisecond)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	done := make(chan error, 2)
+	for range 2 {
+		go func() {
+			done <- cb.Do(ctx, func(context.Context) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+		}()
+	}
+	<-started
+	<-started
+
+	err := cb.Do(ctx, func(context.Context) error { return nil })
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	require.Equal(t, CircuitStateClosed, cb.State())
+}
+
+func TestCircuitBreakerReleasesProbeOnPanic(t *testing.T) {
+	ctx := context.Background()
+
+	cb := NewCircuitBreaker("test-panic",
+		WithConsecutiveFailures(1),
+		WithOpenBackoff(StaticBackoff{Sleep: 10 * time.Millisecond}),
+	)
+
+	_ = cb.Do(ctx, func(context.Context) error { return errors.New("fail") })
+	time.Sleep(20 * time.Millisecond)
+
+	require.Panics(t, func() {
+		_ = cb.Do(ctx, func(context.Context) error { panic("boom") })
+	})
+
+	require.NoError(t, cb.Do(ctx, func(context.Context) error { return nil }))
+	require.Equal(t, CircuitStateClosed, cb.State())
+}
+
+func TestCircuitBreakerIgnoresCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cb := NewCircuitBreaker("test-cancel", WithConsecutiveFailures(1))
+
+	job := cb.Job(JobFunc(func(ctx context.Context) error {
+		return ctx.Err()
+	}))
+
+	for range 3 {
+		err := job.RunOnce(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+
+	require.Equal(t, CircuitStateClosed, cb.State())
+}
+
+func TestCircuitBreakerHealth(t *testing.T) {
+	ctx := logutil.Start(context.Background(), "test-breaker-worker")
+	errFail := errors.New("fail")
+
+	cb := NewCircuitBreaker("test-health",
+		WithConsecutiveFailures(1),
+		WithOpenBackoff(StaticBackoff{Sleep: 10 * time.Millisecond}),
+	)
+
+	HealthCheckpoint(ctx, errFail)
+
+	_ = cb.Do(ctx, func(context.Context) error { return errFail })
+	status := healthStatus(t, "circuit-breaker/test-health")
+	require.Equal(t, HealthStateFiring, status.State)
+	require.True(t, status.Informational)
+	require.NoError(t, status.Ready(time.Now(), 0), "an open breaker must not affect the readiness")
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, cb.Do(ctx, func(context.Context) error { return nil }))
+	require.Equal(t, HealthStateOK, healthStatus(t, "circuit-breaker/test-health").State)
+
+	// Closing the breaker must not resolve the failure of the worker.
+	require.Equal(t, HealthStateFiring, healthStatus(t, logutil.GetSubsystem(ctx)).State)
+}
+
+func healthStatus(t *testing.T, name string) HealthStatus {
+	t.Helper()
+
+	for _, status := range HealthReport() {
+		if status.Name == name {
+			return status
+		}
+	}
+
+	t.Fatalf("no health status for %s", name)
+	return HealthStatus{}
+}
+
+func TestCircuitBreakerBackoffStop(t *testing.T) {
+	ctx := context.Background()
+	errFail := errors.New("fail")
+
+	cb := NewCircuitBreaker("test-backoff-stop",
+		WithConsecutiveFailures(1),
+		WithOpenBackoff(BackoffWithMaxAttempts{
+			Backoff:     StaticBackoff{Sleep: time.Hour},
+			MaxAttempts: 2,
+		}),
+	)
+
+	_ = cb.Do(ctx, func(context.Context) error { return errFail })
+	require.Equal(t, CircuitStateOpen, cb.State())
+
+	// Force the half-open state, to open the breaker a second time, which
+	// makes the backoff return BackoffStop.
+	cb.mu.Lock()
+	cb.openUntil = time.Now()
+	cb.mu.Unlock()
+	require.Equal(t, CircuitStateHalfOpen, cb.State())
+
+	_ = cb.Do(ctx, func(context.Context) error { return errFail })
+	require.Equal(t, CircuitStateOpen, cb.State())
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, cb.Do(ctx, func(context.Context) error { return nil }), &openErr)
+	require.Greater(t, openErr.RetryAfter(), 59*time.Minute)
+}
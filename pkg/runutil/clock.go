@@ -0,0 +1,123 @@
+package runutil
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Clock is the source of time for the workers of this package. It can be
+// replaced with [WithClock] to test schedules without real sleeps. See the
+// package fakeclock for a deterministic implementation.
+//
+// [Wait], [Repeat], [Cron], [Triggered], [RetryJob], [Retry],
+// [DistributedRepeat], [LeaderElection] and [Supervisor] use the clock of
+// their context. [CircuitBreaker] and the rate limiters share their state
+// between calls, therefore their clock is set with [WithCircuitBreakerClock]
+// and [WithRateLimiterClock].
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the [Clock] equivalent of [time.Timer].
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the [Clock] equivalent of [time.Ticker].
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// RandSource is the source of randomness for jitter. It is satisfied by
+// [*rand.Rand], but implementations must be safe for concurrent use.
+type RandSource interface {
+	Float64() float64
+	Int63n(n int64) int64
+}
+
+type clockContextKey struct{}
+
+type randContextKey struct{}
+
+// WithClock returns a context that makes the workers of this package use the
+// given [Clock].
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockContextKey{}, clock)
+}
+
+// ClockFromContext returns the [Clock] of the context or the real clock, if
+// there is none.
+func ClockFromContext(ctx context.Context) Clock {
+	clock, ok := ctx.Value(clockContextKey{}).(Clock)
+	if !ok {
+		return realClock{}
+	}
+
+	return clock
+}
+
+// WithRandSource returns a context that makes the workers of this package use
+// the given [RandSource] for jitter. This is useful to get reproducible
+// schedules in tests.
+func WithRandSource(ctx context.Context, src RandSource) context.Context {
+	return context.WithValue(ctx, randContextKey{}, src)
+}
+
+func randFromContext(ctx context.Context) RandSource {
+	src, ok := ctx.Value(randContextKey{}).(RandSource)
+	if !ok {
+		return globalRand{}
+	}
+
+	return src
+}
+
+// randOrGlobal returns the given source or the global one, if it is nil. It
+// is used by the backoffs, which have an optional Rand field.
+func randOrGlobal(src RandSource) RandSource {
+	if src == nil {
+		return globalRand{}
+	}
+
+	return src
+}
+
+// globalRand uses the functions of math/rand, which are safe for concurrent
+// use.
+type globalRand struct{}
+
+func (globalRand) Float64() float64     { return rand.Float64() }
+func (globalRand) Int63n(n int64) int64 { return rand.Int63n(n) }
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time   { return t.t.C }
+func (t realTicker) Stop()                 { t.t.Stop() }
+func (t realTicker) Reset(d time.Duration) { t.t.Reset(d) }
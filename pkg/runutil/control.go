@@ -0,0 +1,239 @@
+package runutil
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+var jobControlRegistry = &jobControlRegistryImpl{
+	controls: map[string]*JobControl{},
+}
+
+// JobControl allows to trigger, pause and resume a scheduled job (eg [Repeat],
+// [Cron] or [DistributedRepeat]) at runtime. The scheduling workers register
+// a control with a unique ID, named after the logutil subsystem of their
+// context, when they start and unregister it when they return. Therefore
+// workers with the same subsystem name get separate controls and a restarted
+// worker (eg by [Retry]) starts unpaused with a new ID. Use [JobStatuses] to
+// find the ID and [GetJobControl] to retrieve the control. Workers without a
+// subsystem name do not get registered.
+type JobControl struct {
+	id      string
+	name    string
+	trigger chan struct{}
+
+	mu        sync.Mutex
+	paused    bool
+	running   int
+	lastStart time.Time
+	lastEnd   time.Time
+	lastError error
+}
+
+// JobStatus is a snapshot of the state of a [JobControl].
+type JobStatus struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Paused    bool      `json:"paused"`
+	Running   bool      `json:"running"`
+	Triggered bool      `json:"triggered"`
+	LastStart time.Time `json:"last_start,omitzero"`
+	LastEnd   time.Time `json:"last_end,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// GetJobControl returns the control of the scheduled job with the given ID
+// (see [JobStatus]). The second return value is false, if there is no such job
+// running.
+func GetJobControl(id string) (*JobControl, bool) {
+	return jobControlRegistry.lookup(id)
+}
+
+// JobStatuses returns the status of all registered scheduled jobs, sorted by
+// name and ID.
+func JobStatuses() []JobStatus {
+	return jobControlRegistry.statuses()
+}
+
+func newJobControl(id, name string) *JobControl {
+	return &JobControl{
+		id:      id,
+		name:    name,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// registerJobControl creates a control for the subsystem of the context. The
+// returned function unregisters it again and must be called, when the worker
+// returns.
+func registerJobControl(ctx context.Context) (*JobControl, func()) {
+	name := logutil.GetSubsystem(ctx)
+	if name == "" {
+		return newJobControl("", name), func() {}
+	}
+
+	return jobControlRegistry.register(name)
+}
+
+// Trigger runs the job as soon as possible, regardless of its schedule and
+// even if it is paused. Multiple triggers before the job starts result in a
+// single run.
+func (c *JobControl) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Pause skips all scheduled runs until [JobControl.Resume] gets called. A
+// running job is not interrupted.
+func (c *JobControl) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paused = true
+}
+
+// Resume continues the scheduled runs after [JobControl.Pause]. Missed runs
+// are not executed.
+func (c *JobControl) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paused = false
+}
+
+// Paused returns true, if the job is paused.
+func (c *JobControl) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.paused
+}
+
+// Status returns a snapshot of the state of the job.
+func (c *JobControl) Status() JobStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := JobStatus{
+		ID:        c.id,
+		Name:      c.name,
+		Paused:    c.paused,
+		Running:   c.running > 0,
+		Triggered: len(c.trigger) > 0,
+		LastStart: c.lastStart,
+		LastEnd:   c.lastEnd,
+	}
+
+	if c.lastError != nil {
+		status.LastError = c.lastError.Error()
+	}
+
+	return status
+}
+
+// triggered returns the channel that receives manual triggers.
+func (c *JobControl) triggered() <-chan struct{} {
+	return c.trigger
+}
+
+// skip returns true, if a scheduled run should be skipped because the job is
+// paused. It logs the skipped run.
+func (c *JobControl) skip(ctx context.Context) bool {
+	if !c.Paused() {
+		return false
+	}
+
+	logutil.Get(ctx).Debug("skipping scheduled run of paused job")
+	return true
+}
+
+// run executes the job with [runJob] and tracks its state.
+func (c *JobControl) run(ctx context.Context, job Job) error {
+	clock := ClockFromContext(ctx)
+
+	c.mu.Lock()
+	c.running++
+	c.lastStart = clock.Now()
+	c.mu.Unlock()
+
+	var err error
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.running--
+		c.lastEnd = clock.Now()
+		c.lastError = err
+	}()
+
+	err = runJob(ctx, job)
+	return err
+}
+
+type jobControlRegistryImpl struct {
+	controls map[string]*JobControl
+	lastID   uint64
+	mu       sync.Mutex
+}
+
+func (r *jobControlRegistryImpl) register(name string) (*JobControl, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	control := newJobControl(strconv.FormatUint(r.lastID, 10), name)
+	r.controls[control.id] = control
+
+	return control, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.controls, control.id)
+	}
+}
+
+func (r *jobControlRegistryImpl) lookup(id string) (*JobControl, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	control, ok := r.controls[id]
+	return control, ok
+}
+
+func (r *jobControlRegistryImpl) statuses() []JobStatus {
+	r.mu.Lock()
+	controls := make([]*JobControl, 0, len(r.controls))
+	for _, c := range r.controls {
+		controls = append(controls, c)
+	}
+	r.mu.Unlock()
+
+	result := make([]JobStatus, 0, len(controls))
+	for _, c := range controls {
+		result = append(result, c.Status())
+	}
+
+	slices.SortFunc(result, func(a, b JobStatus) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return compareJobIDs(a.ID, b.ID)
+	})
+
+	return result
+}
+
+// compareJobIDs sorts the numeric IDs in the order of registration.
+func compareJobIDs(a, b string) int {
+	if c := len(a) - len(b); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,172 @@
+package runutil
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForJobControl returns the control of the running job with the given
+// subsystem name.
+func waitForJobControl(t *testing.T, name string) *JobControl {
+	t.Helper()
+
+	var control *JobControl
+	require.Eventually(t, func() bool {
+		for _, status := range JobStatuses() {
+			if status.Name == name {
+				var ok bool
+				control, ok = GetJobControl(status.ID)
+				return ok
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	return control
+}
+
+func TestRepeatTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = logutil.Start(ctx, "test-repeat-trigger")
+
+	var calls atomic.Int32
+	worker := Repeat(time.Hour, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	control := waitForJobControl(t, logutil.GetSubsystem(ctx))
+	control.Trigger()
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	status := control.Status()
+	assert.False(t, status.Running)
+	assert.False(t, status.LastStart.IsZero())
+	assert.Empty(t, status.LastError)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestRepeatPause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = logutil.Start(ctx, "test-repeat-pause")
+
+	var calls atomic.Int32
+	worker := Repeat(5*time.Millisecond, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	control := waitForJobControl(t, logutil.GetSubsystem(ctx))
+	control.Pause()
+
+	// A run might have started before the pause.
+	time.Sleep(10 * time.Millisecond)
+	paused := calls.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, paused, calls.Load())
+	assert.True(t, control.Status().Paused)
+
+	control.Trigger()
+	require.Eventually(t, func() bool { return calls.Load() == paused+1 }, time.Second, time.Millisecond,
+		"trigger must run a paused job")
+
+	control.Resume()
+	require.Eventually(t, func() bool { return calls.Load() > paused+2 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestDistributedRepeatTrigger(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = logutil.Start(ctx, "test-distributed-trigger")
+
+	var calls atomic.Int32
+	worker := NewDistributedRepeat(client, "test-trigger", time.Hour, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// The lock is still held by this replica because of the cooldown, but
+	// the trigger must still work.
+	control := waitForJobControl(t, logutil.GetSubsystem(ctx))
+	control.Trigger()
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestJobControlRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = logutil.Start(ctx, "test-job-control-registry")
+	name := logutil.GetSubsystem(ctx)
+
+	var calls atomic.Int32
+	job := JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	done := make(chan error, 2)
+	for range 2 {
+		go func() { done <- Repeat(time.Hour, job).Run(ctx) }()
+	}
+
+	var statuses []JobStatus
+	require.Eventually(t, func() bool {
+		statuses = nil
+		for _, status := range JobStatuses() {
+			if status.Name == name {
+				statuses = append(statuses, status)
+			}
+		}
+		return len(statuses) == 2
+	}, time.Second, time.Millisecond)
+
+	assert.NotEqual(t, statuses[0].ID, statuses[1].ID,
+		"workers with the same subsystem must not share a control")
+
+	first, ok := GetJobControl(statuses[0].ID)
+	require.True(t, ok)
+	first.Pause()
+
+	second, ok := GetJobControl(statuses[1].ID)
+	require.True(t, ok)
+	assert.False(t, second.Paused())
+
+	cancel()
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	for _, status := range statuses {
+		_, ok := GetJobControl(status.ID)
+		assert.False(t, ok, "the control must be unregistered after the worker returned")
+	}
+}
@@ -0,0 +1,431 @@
+package runutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+// MissedRunPolicy defines what a [Cron] worker does, when a run took so long
+// that one or more scheduled runs were missed.
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip drops all missed runs and waits for the next scheduled
+	// time in the future.
+	MissedRunSkip MissedRunPolicy = iota
+
+	// MissedRunCatchUp executes each missed run immediately, one after
+	// another, until the schedule is caught up.
+	MissedRunCatchUp
+)
+
+type cronWorker struct {
+	spec     string
+	job      Job
+	location *time.Location
+	missed   MissedRunPolicy
+}
+
+// Cron reruns a job indefinitely until the context gets cancelled. The job is
+// executed at the times defined by the cron expression. See [ParseCron] for the
+// supported syntax. Like [Repeat], the worker stops when the job returns an
+// error.
+//
+// An invalid expression does not panic, but makes the worker return the parse
+// error as soon as it is started.
+//
+// The job can be triggered, paused and resumed at runtime with its
+// [JobControl].
+func Cron(spec string, job Job, opts ...CronOption) Worker {
+	w := &cronWorker{
+		spec: spec,
+		job:  job,
+	}
+
+	for _, o := range opts {
+		o(w)
+	}
+
+	return w
+}
+
+type CronOption func(*cronWorker)
+
+// WithCronLocation sets the time zone for the cron expression. It is ignored,
+// if the expression itself defines a time zone with the CRON_TZ prefix.
+// Defaults to [time.Local].
+func WithCronLocation(loc *time.Location) CronOption {
+	return func(w *cronWorker) {
+		w.location = loc
+	}
+}
+
+// WithMissedRunPolicy defines what happens with runs that were missed because
+// the previous run took too long. Defaults to [MissedRunSkip].
+func WithMissedRunPolicy(policy MissedRunPolicy) CronOption {
+	return func(w *cronWorker) {
+		w.missed = policy
+	}
+}
+
+func (w *cronWorker) Run(ctx context.Context) error {
+	schedule, err := ParseCron(w.spec)
+	if err != nil {
+		return err
+	}
+
+	if schedule.location == nil {
+		schedule.location = w.location
+	}
+
+	control, unregister := registerJobControl(ctx)
+	defer unregister()
+
+	clock := ClockFromContext(ctx)
+
+	next := schedule.Next(clock.Now())
+	for {
+		if next.IsZero() {
+			return fmt.Errorf("cron expression %q has no future runs", w.spec)
+		}
+
+		logutil.Get(ctx).Debug("waiting for next cron run", "next", next)
+
+		timer := clock.NewTimer(next.Sub(clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-control.triggered():
+			timer.Stop()
+			logutil.Get(ctx).Info("running manually triggered job")
+
+			// A triggered run does not affect the schedule.
+			err := control.run(ctx, w.job)
+			if err != nil {
+				return err
+			}
+			continue
+		case <-timer.C():
+		}
+
+		if !control.skip(ctx) {
+			err := control.run(ctx, w.job)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := clock.Now()
+		next = schedule.Next(next)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		switch w.missed {
+		case MissedRunCatchUp:
+			logutil.Get(ctx).Info("catching up missed cron run", "scheduled", next)
+		default:
+			missed := next
+			next = schedule.Next(now)
+			logutil.Get(ctx).Warn("skipping missed cron runs", "first-missed", missed, "next", next)
+		}
+	}
+}
+
+// CronSchedule is a parsed cron expression. Use [ParseCron] to create one.
+type CronSchedule struct {
+	seconds, minutes, hours, doms, months, dows uint64
+
+	// domStar and dowStar are needed for the special behaviour of cron, that
+	// matches the day if either the day-of-month or day-of-week matches, if
+	// both are restricted.
+	domStar, dowStar bool
+
+	location *time.Location
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSeconds = cronField{name: "second", min: 0, max: 59}
+	cronMinutes = cronField{name: "minute", min: 0, max: 59}
+	cronHours   = cronField{name: "hour", min: 0, max: 23}
+	cronDoms    = cronField{name: "day-of-month", min: 1, max: 31}
+	cronMonths  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDows = cronField{name: "day-of-week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseCron parses a cron expression. It supports:
+//
+//   - the standard 5 fields (minute, hour, day-of-month, month, day-of-week),
+//   - an optional leading seconds field, resulting in 6 fields,
+//   - lists (1,2,3), ranges (1-5), steps (*/15, 10-40/10) and the names of
+//     months (JAN-DEC) and weekdays (SUN-SAT),
+//   - the descriptors @yearly, @annually, @monthly, @weekly, @daily,
+//     @midnight and @hourly,
+//   - a time zone prefix, like "CRON_TZ=Europe/Berlin 0 3 * * MON-FRI".
+//
+// Like in the original cron, a day matches if either the day-of-month or the
+// day-of-week matches, when both fields are restricted.
+func ParseCron(spec string) (*CronSchedule, error) {
+	schedule := new(CronSchedule)
+
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		prefix, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(prefix, "=")
+
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("load cron time zone %q: %w", name, err)
+		}
+
+		schedule.location = loc
+		spec = strings.TrimSpace(rest)
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := cronDescriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron descriptor %q", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression %q must have 5 or 6 fields, got %d", spec, len(fields))
+	}
+
+	var err error
+	for _, f := range []struct {
+		field cronField
+		value string
+		dst   *uint64
+	}{
+		{cronSeconds, fields[0], &schedule.seconds},
+		{cronMinutes, fields[1], &schedule.minutes},
+		{cronHours, fields[2], &schedule.hours},
+		{cronDoms, fields[3], &schedule.doms},
+		{cronMonths, fields[4], &schedule.months},
+		{cronDows, fields[5], &schedule.dows},
+	} {
+		*f.dst, err = f.field.parse(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("parse cron expression %q: %w", spec, err)
+		}
+	}
+
+	// Sunday can be specified as 0 or 7.
+	if schedule.dows&(1<<7) != 0 {
+		schedule.dows |= 1
+	}
+
+	schedule.domStar = isCronWildcard(fields[3])
+	schedule.dowStar = isCronWildcard(fields[5])
+
+	return schedule, nil
+}
+
+func isCronWildcard(value string) bool {
+	return strings.HasPrefix(value, "*") || strings.HasPrefix(value, "?")
+}
+
+func (f cronField) parse(value string) (uint64, error) {
+	var bits uint64
+
+	for _, term := range strings.Split(value, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(term, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepExpr, f.name)
+			}
+		}
+
+		var start, end int
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+			start, end = f.min, f.max
+			if f.name == cronDows.name {
+				// Avoid matching Sunday twice.
+				end = 6
+			}
+		case strings.Contains(rangeExpr, "-"):
+			lo, hi, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			start, err = f.value(lo)
+			if err != nil {
+				return 0, err
+			}
+			end, err = f.value(hi)
+			if err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			start, err = f.value(rangeExpr)
+			if err != nil {
+				return 0, err
+			}
+			end = start
+			if hasStep {
+				end = f.max
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q in %s field", rangeExpr, f.name)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, f.name)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d] in %s field", v, f.min, f.max, f.name)
+	}
+
+	return v, nil
+}
+
+// Next returns the next time after t that matches the schedule. It returns
+// the zero time, if there is no matching time within the next five years.
+//
+// The schedule is evaluated on the wall clock of the configured location
+// (defaults to the location of t). This affects daylight saving time
+// transitions:
+//
+//   - Wall clock times that get skipped run shifted forward by the length of
+//     the gap (eg 02:30 runs at 03:30, when clocks jump from 02:00 to 03:00).
+//   - Wall clock times that occur twice (eg 02:30 when clocks jump from 03:00
+//     back to 02:00) run only once.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	loc := s.location
+	if loc == nil {
+		loc = t.Location()
+	}
+
+	t = t.In(loc)
+	civil := cronWallClock(t)
+
+	for {
+		civil = s.nextCivil(civil)
+		if civil.IsZero() {
+			return time.Time{}
+		}
+
+		next := time.Date(civil.Year(), civil.Month(), civil.Day(),
+			civil.Hour(), civil.Minute(), civil.Second(), 0, loc)
+		if cronWallClock(next).Before(civil) {
+			// The wall clock time got skipped and time.Date resolved it with
+			// the offset after the transition, which moves it backwards.
+			// The offset before the transition moves it forward instead.
+			_, offset := next.Zone()
+			next = civil.Add(-time.Duration(offset) * time.Second).In(loc)
+		}
+
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// cronWallClock returns the wall clock of t in UTC, like the times used by
+// nextCivil.
+func cronWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// nextCivil returns the next matching wall clock time after c. The given and
+// returned times are in UTC, which has no daylight saving time and therefore
+// represents the plain wall clock.
+func (s *CronSchedule) nextCivil(c time.Time) time.Time {
+	c = c.Add(time.Second)
+	limit := c.Year() + 5
+
+	for c.Year() <= limit {
+		y, m, d := c.Date()
+		h, mi, sec := c.Clock()
+
+		switch {
+		case !cronHas(s.months, int(m)):
+			c = time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(c):
+			c = time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+		case !cronHas(s.hours, h):
+			c = time.Date(y, m, d, h+1, 0, 0, 0, time.UTC)
+		case !cronHas(s.minutes, mi):
+			c = time.Date(y, m, d, h, mi+1, 0, 0, time.UTC)
+		case !cronHas(s.seconds, sec):
+			c = c.Add(time.Second)
+		default:
+			return c
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	var (
+		dom = cronHas(s.doms, t.Day())
+		dow = cronHas(s.dows, int(t.Weekday()))
+	)
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+func cronHas(bits uint64, i int) bool {
+	return bits&(1<<uint(i)) != 0
+}
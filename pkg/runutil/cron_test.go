@@ -0,0 +1,266 @@
+package runutil
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"foo * * * *",
+		"@every",
+		"CRON_TZ=Mars/Olympus * * * * *",
+	}
+
+	for _, spec := range cases {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseCron(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	cases := []struct {
+		spec string
+		from string
+		want []string
+	}{
+		{
+			spec: "*/15 * * * *",
+			from: "2024-01-01T10:07:00Z",
+			want: []string{"2024-01-01T10:15:00Z", "2024-01-01T10:30:00Z", "2024-01-01T10:45:00Z", "2024-01-01T11:00:00Z"},
+		},
+		{
+			spec: "30 */20 * * * *",
+			from: "2024-01-01T10:00:30Z",
+			want: []string{"2024-01-01T10:20:30Z", "2024-01-01T10:40:30Z", "2024-01-01T11:00:30Z"},
+		},
+		{
+			spec: "0 3 * * MON-FRI",
+			from: "2024-01-05T04:00:00Z", // Friday
+			want: []string{"2024-01-08T03:00:00Z", "2024-01-09T03:00:00Z"},
+		},
+		{
+			spec: "0 0 1,15 * 0",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-07T00:00:00Z", "2024-01-14T00:00:00Z", "2024-01-15T00:00:00Z", "2024-01-21T00:00:00Z"},
+		},
+		{
+			spec: "0 12 29 feb *",
+			from: "2024-03-01T00:00:00Z",
+			want: []string{"2028-02-29T12:00:00Z"},
+		},
+		{
+			spec: "@monthly",
+			from: "2024-01-31T12:00:00Z",
+			want: []string{"2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"},
+		},
+		{
+			spec: "0 0 * * 7",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-07T00:00:00Z"},
+		},
+		{
+			spec: "CRON_TZ=Europe/Berlin 0 3 * * *",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-01T02:00:00Z", "2024-01-02T02:00:00Z"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.spec, func(t *testing.T) {
+			schedule, err := ParseCron(tc.spec)
+			require.NoError(t, err)
+
+			from, err := time.Parse(time.RFC3339, tc.from)
+			require.NoError(t, err)
+
+			next := from
+			for _, w := range tc.want {
+				want, err := time.Parse(time.RFC3339, w)
+				require.NoError(t, err)
+
+				next = schedule.Next(next)
+				assert.True(t, want.Equal(next), "want %v, have %v", want, next)
+			}
+		})
+	}
+
+	t.Run("impossible", func(t *testing.T) {
+		schedule, err := ParseCron("0 0 30 2 *")
+		require.NoError(t, err)
+		assert.True(t, schedule.Next(time.Now()).IsZero())
+	})
+
+	t.Run("location-of-input", func(t *testing.T) {
+		schedule, err := ParseCron("0 3 * * *")
+		require.NoError(t, err)
+
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, berlin)
+		want := time.Date(2024, 1, 1, 3, 0, 0, 0, berlin)
+		assert.True(t, want.Equal(schedule.Next(from)))
+	})
+}
+
+func TestCronScheduleNextDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	t.Run("spring-forward", func(t *testing.T) {
+		// On 2024-03-31 clocks jump from 02:00 CET to 03:00 CEST.
+		schedule, err := ParseCron("30 2 * * *")
+		require.NoError(t, err)
+
+		from := time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)
+		next := schedule.Next(from)
+		assert.Equal(t, time.Date(2024, 3, 31, 3, 30, 0, 0, berlin), next)
+
+		next = schedule.Next(next)
+		assert.Equal(t, time.Date(2024, 4, 1, 2, 30, 0, 0, berlin), next)
+	})
+
+	t.Run("spring-forward-shift", func(t *testing.T) {
+		newYork := mustLoadLocation(t, "America/New_York")
+
+		cases := []struct {
+			name string
+			spec string
+			from time.Time
+			want time.Time
+		}{
+			{
+				name: "start of gap",
+				spec: "0 2 * * *",
+				from: time.Date(2024, 3, 31, 0, 0, 0, 0, berlin),
+				want: time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), // 03:00 CEST
+			},
+			{
+				name: "middle of gap",
+				spec: "30 2 * * *",
+				from: time.Date(2024, 3, 31, 0, 0, 0, 0, berlin),
+				want: time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC), // 03:30 CEST
+			},
+			{
+				name: "end of gap",
+				spec: "59 2 * * *",
+				from: time.Date(2024, 3, 31, 0, 0, 0, 0, berlin),
+				want: time.Date(2024, 3, 31, 1, 59, 0, 0, time.UTC), // 03:59 CEST
+			},
+			{
+				name: "other location",
+				spec: "30 2 * * *",
+				from: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork),
+				want: time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC), // 03:30 EDT
+			},
+			{
+				name: "right before gap",
+				spec: "30 2 * * *",
+				from: time.Date(2024, 3, 10, 1, 45, 0, 0, newYork),
+				want: time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC), // 03:30 EDT
+			},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				schedule, err := ParseCron(tc.spec)
+				require.NoError(t, err)
+
+				next := schedule.Next(tc.from)
+				assert.True(t, tc.want.Equal(next), "want %s, got %s", tc.want, next.UTC())
+			})
+		}
+	})
+
+	t.Run("fall-back", func(t *testing.T) {
+		// On 2024-10-27 clocks jump from 03:00 CEST back to 02:00 CET.
+		schedule, err := ParseCron("30 2 * * *")
+		require.NoError(t, err)
+
+		from := time.Date(2024, 10, 27, 0, 0, 0, 0, berlin)
+		first := schedule.Next(from)
+		assert.Equal(t, 2, first.Hour())
+		assert.Equal(t, 30, first.Minute())
+
+		next := schedule.Next(first)
+		assert.Equal(t, time.Date(2024, 10, 28, 2, 30, 0, 0, berlin), next)
+	})
+
+	t.Run("hourly-spring-forward", func(t *testing.T) {
+		newYork := mustLoadLocation(t, "America/New_York")
+		schedule, err := ParseCron("0 * * * *")
+		require.NoError(t, err)
+
+		from := time.Date(2024, 3, 10, 0, 30, 0, 0, newYork)
+		next := from
+		for range 5 {
+			prev := next
+			next = schedule.Next(next)
+			assert.True(t, next.After(prev))
+			assert.LessOrEqual(t, next.Sub(prev), time.Hour)
+		}
+	})
+
+	t.Run("hourly-fall-back", func(t *testing.T) {
+		schedule, err := ParseCron("0 * * * *")
+		require.NoError(t, err)
+
+		from := time.Date(2024, 10, 27, 0, 30, 0, 0, berlin)
+		next := from
+		for range 5 {
+			prev := next
+			next = schedule.Next(next)
+			assert.True(t, next.After(prev))
+			assert.LessOrEqual(t, next.Sub(prev), 2*time.Hour)
+		}
+	})
+}
+
+func TestCronInvalidSpecReturnsError(t *testing.T) {
+	w := Cron("invalid", JobFunc(func(ctx context.Context) error {
+		return nil
+	}))
+
+	err := w.Run(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCronRunsJob(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var calls atomic.Int32
+	w := Cron("* * * * * *", JobFunc(func(ctx context.Context) error {
+		if calls.Add(1) >= 2 {
+			cancel()
+		}
+		return nil
+	}))
+
+	err := w.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
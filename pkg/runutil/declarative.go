@@ -9,10 +9,32 @@ import (
 // field in the most sensful order.
 //
 // It satisfies the Worker interface for easier use.
+//
+// The fields Restart, Optional and ShutdownPhase are only used by
+// [RunAllWorkers] and [RunProvidedWorkers], which run the workers in a
+// [Supervisor]. See [ChildSpec].
 type DeclarativeWorker struct {
 	Name   string
 	Worker Worker
 	Retry  Backoff
+
+	// RecoverPanics converts panics of the worker into a [*PanicError], which
+	// then gets handled like any other error. See [Recover].
+	RecoverPanics bool
+
+	Restart       RestartPolicy
+	Optional      bool
+	ShutdownPhase int
+}
+
+func (w DeclarativeWorker) childSpec() ChildSpec {
+	return ChildSpec{
+		Worker:        w,
+		Name:          w.Name,
+		Restart:       w.Restart,
+		Optional:      w.Optional,
+		ShutdownPhase: w.ShutdownPhase,
+	}
 }
 
 func (w DeclarativeWorker) Run(ctx context.Context) error {
@@ -26,6 +48,10 @@ func (w DeclarativeWorker) Run(ctx context.Context) error {
 		return inner.Run(ctx)
 	})
 
+	if w.RecoverPanics {
+		worker = Recover(worker)
+	}
+
 	if w.Name != "" {
 		worker = NamedWorker(worker, w.Name)
 	}
@@ -48,21 +48,24 @@ func ProvideWorker(c *dig.Container, fn any) error {
 }
 
 // RunProvidedWorkers starts all workers there were injected using
-// RunAllWorkers.
+// RunAllWorkers. The Restart and Optional fields of a [DeclarativeWorker] are
+// respected.
+//
+// Workers of a configer, that references a [Readier] configer in one of its
+// fields, start only after the Readier is ready. A dependency cycle results in
+// an error before any worker starts. The graph can be inspected with
+// [WorkerGraph].
 func RunProvidedWorkers(ctx context.Context, c *dig.Container) error {
 	return c.Invoke(func(in WorkerGroup) error {
-		workers := []Worker{}
-		for _, c := range in.All {
-			if c == nil {
-				continue
-			}
-
-			for _, w := range c.Workers() {
-				workers = append(workers,
-					NamedWorkerFromType(w, c),
-				)
-			}
+		graph, err := newWorkerGraph(in.All)
+		if err != nil {
+			return err
 		}
-		return RunAllWorkers(ctx, workers...)
+
+		workerGraphRegistry.mu.Lock()
+		workerGraphRegistry.graph = graph
+		workerGraphRegistry.mu.Unlock()
+
+		return NewSupervisor(graph.childSpecs()).Run(ctx)
 	})
 }
@@ -2,23 +2,36 @@ package runutil
 
 import (
 	"context"
-	"fmt"
-	"math/rand"
-	"os"
+	"errors"
 	"sync"
 	"time"
 
-	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
-	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 	"github.com/redis/go-redis/v9"
 )
 
+// errLockLost is used as context cause, when a [DistributedRepeat] lost its
+// lock while the job was still running.
+var errLockLost = errors.New("repeat lock lost")
+
 type DistributedRepeat struct {
-	client   redis.UniversalClient
-	name     string
+	locker   Locker
 	cooldown time.Duration
 	job      Job
+
+	releaseOnCompletion bool
+}
+
+type DistributedRepeatOption func(*DistributedRepeat)
+
+// WithReleaseOnCompletion releases the lock as soon as the job finished,
+// instead of keeping it until the cooldown expired. This means the lock only
+// prevents concurrent runs, while the cooldown is only respected by each
+// replica individually.
+func WithReleaseOnCompletion() DistributedRepeatOption {
+	return func(r *DistributedRepeat) {
+		r.releaseOnCompletion = true
+	}
 }
 
 // NewDistributedRepeat creates a [runutil.Worker] from a [runutil.Job] similar to [runutil.Repeat]. The difference is
@@ -28,25 +41,45 @@ type DistributedRepeat struct {
 // Therefore we can simplify things by using SetNX directly. The lock does not have any correctness guarantees.
 //
 // The lock gets refreshed every tenth of the cooldown to prevent issues when the job takes longer than the cooldown.
+// If the lock gets lost while the job is running (eg because it expired and another replica took it over), the
+// context of the job gets cancelled. Jobs that write to external systems can use the fencing token from
+// [FencingToken] to reject writes from replicas that lost the lock in the meantime.
+//
+// The job can be triggered, paused and resumed at runtime with its [JobControl]. A triggered run still needs the
+// lock, therefore it only happens if no other replica holds it.
 //
 // [1]: https://martin.kleppmann.com/2016/02/08/how-to-do-distributed-locking.html
-func NewDistributedRepeat(client redis.UniversalClient, name string, cooldown time.Duration, job Job) Worker {
-	return &DistributedRepeat{
-		client:   client,
-		name:     name,
+func NewDistributedRepeat(client redis.UniversalClient, name string, cooldown time.Duration, job Job, opts ...DistributedRepeatOption) Worker {
+	return NewDistributedRepeatWithLocker(NewRedisLocker(client, name), cooldown, job, opts...)
+}
+
+// NewDistributedRepeatWithLocker is the same as [NewDistributedRepeat], but
+// uses the given [Locker] for coordinating the repeats.
+func NewDistributedRepeatWithLocker(locker Locker, cooldown time.Duration, job Job, opts ...DistributedRepeatOption) Worker {
+	r := &DistributedRepeat{
+		locker:   locker,
 		cooldown: cooldown,
 		job:      job,
 	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
 }
 
 func (r *DistributedRepeat) Run(ctx context.Context) error {
-	hostname, err := os.Hostname()
-	if err != nil {
-		return fmt.Errorf("get hostname: %w", err)
-	}
+	control, unregister := registerJobControl(ctx)
+	defer unregister()
+
+	var (
+		triggered bool
+		err       error
+	)
 
 	for ctx.Err() == nil {
-		err := r.attemptExecution(ctx, hostname)
+		triggered, err = r.attemptExecution(ctx, control, triggered)
 		if err != nil {
 			return err
 		}
@@ -55,82 +88,175 @@ func (r *DistributedRepeat) Run(ctx context.Context) error {
 	return nil
 }
 
-func (r *DistributedRepeat) attemptExecution(ctx context.Context, hostname string) error {
-	ok, err := r.client.SetNX(ctx, r.name, hostname, r.cooldown).Result()
-	if err != nil {
-		return fmt.Errorf("setnx %#v for lock: %w", r.name, err)
-	}
-
-	if ok {
-		ticker := time.NewTicker(r.cooldown / 10)
-		done := make(chan struct{})
-		var wg sync.WaitGroup
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-done:
-					return
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					// Use a timeout context to prevent blocking indefinitely on cancelled parent context
-					expireCtx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-					err := r.client.Expire(expireCtx, r.name, r.cooldown).Err()
-					cancel()
-					if err != nil {
-						logutil.Get(ctx).Error("refreshing repeat lock", "error", err)
-					}
-				}
-			}
-		}()
-
-		err := r.runOnce(ctx)
-		close(done)
-		wg.Wait()
+// attemptExecution tries to run the job and waits until the next attempt. It
+// returns true, if the next attempt got triggered manually.
+func (r *DistributedRepeat) attemptExecution(ctx context.Context, control *JobControl, triggered bool) (bool, error) {
+	var (
+		wait time.Duration
+		err  error
+	)
 
+	if triggered || !control.skip(ctx) {
+		wait, err = r.execute(ctx, control, triggered)
 		if err != nil {
-			return err
+			return false, err
 		}
+	} else {
+		wait = r.cooldown
 	}
 
-	wait, err := r.client.TTL(ctx, r.name).Result()
-	if err != nil {
-		return fmt.Errorf("get ttl %#v for lock: %w", r.name, err)
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	if wait == 0 {
+		wait, err = r.locker.TTL(ctx)
+		if err != nil {
+			return false, err
+		}
 	}
 
 	// add jitter of 0% - 5% of total wait time
-	jitter := time.Duration(float64(r.cooldown) / 20. * rand.Float64())
+	jitter := time.Duration(float64(r.cooldown) / 20. * randFromContext(ctx).Float64())
 
 	logutil.Get(ctx).Debug("distributed sleep", "wait", wait, "jitter", jitter, "total", wait+jitter)
 
 	select {
-	case <-time.After(wait + jitter):
+	case <-ClockFromContext(ctx).After(wait + jitter):
+	case <-control.triggered():
+		logutil.Get(ctx).Info("running manually triggered job")
+		return true, nil
 	case <-ctx.Done():
 	}
 
-	return nil
+	return false, nil
 }
 
-func (r *DistributedRepeat) runOnce(ctx context.Context) error {
-	span, ctx := tracer.StartSpanFromContext(
-		ctx, "runutil.job",
-		tracer.Tag(ext.SpanKind, ext.SpanKindInternal),
-		tracer.Tag(ext.ResourceName, logutil.GetSubsystem(ctx)),
-	)
-	err := r.job.RunOnce(ctx)
-	HealthCheckpoint(ctx, err)
+// execute runs the job, if it can acquire the lock. It returns the time to
+// wait before the next attempt or zero, if the wait time should be derived
+// from the TTL of the lock.
+func (r *DistributedRepeat) execute(ctx context.Context, control *JobControl, triggered bool) (time.Duration, error) {
+	ok, err := r.locker.TryLock(ctx, r.cooldown)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok && triggered {
+		// The lock might still be held by this replica, because it is kept
+		// for the cooldown after the previous run.
+		ok, err = r.locker.Refresh(ctx, r.cooldown)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			logutil.Get(ctx).Warn("ignoring manual trigger, because another replica holds the repeat lock")
+		}
+	}
 
+	if !ok {
+		return 0, nil
+	}
+
+	released, err := r.runLocked(ctx, control)
 	if err != nil {
-		span.Finish(tracer.WithError(err))
-		return err
-	} else {
-		span.Finish()
+		return 0, err
 	}
 
-	return nil
+	if released {
+		return r.cooldown, nil
+	}
+
+	return 0, nil
+}
+
+// runLocked executes the job while the lock is held and keeps refreshing the
+// lock in the background. It returns true, if the lock got released.
+func (r *DistributedRepeat) runLocked(ctx context.Context, control *JobControl) (bool, error) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if fl, ok := r.locker.(FencingLocker); ok {
+		jobCtx = contextWithFencingToken(jobCtx, fl.FencingToken())
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.refresh(ctx, done, cancel)
+	}()
+
+	err := control.run(jobCtx, r.job)
+	close(done)
+	wg.Wait()
+
+	cause := context.Cause(jobCtx)
+	if errors.Is(cause, errLockLost) {
+		logutil.Get(ctx).Warn("job got cancelled, because the repeat lock was lost",
+			"error", cause, "job-error", err)
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !r.releaseOnCompletion {
+		return false, nil
+	}
+
+	// Use a timeout context, because the parent context might already be
+	// cancelled, but the lock should still be released.
+	unlockCtx, unlockCancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+	defer unlockCancel()
+
+	err = r.locker.Unlock(unlockCtx)
+	if err != nil {
+		logutil.Get(ctx).Warn("releasing repeat lock", "error", err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (r *DistributedRepeat) refresh(ctx context.Context, done <-chan struct{}, cancel context.CancelCauseFunc) {
+	clock := ClockFromContext(ctx)
+
+	ticker := clock.NewTicker(r.cooldown / 10)
+	defer ticker.Stop()
+
+	refreshed := clock.Now()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		// Use a timeout context to prevent blocking indefinitely on cancelled parent context
+		refreshCtx, refreshCancel := context.WithTimeout(context.Background(), time.Second*15)
+		ok, err := r.locker.Refresh(refreshCtx, r.cooldown)
+		refreshCancel()
+
+		switch {
+		case err != nil && clock.Now().Sub(refreshed) >= r.cooldown:
+			// The lock definitely expired, since the last successful refresh
+			// is longer ago than its TTL.
+			cancel(errors.Join(errLockLost, err))
+			return
+		case err != nil:
+			logutil.Get(ctx).Error("refreshing repeat lock", "error", err)
+		case !ok:
+			cancel(errLockLost)
+			return
+		default:
+			refreshed = clock.Now()
+		}
+	}
 }
@@ -0,0 +1,104 @@
+package runutil
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistributedRepeatRespectsCooldown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mr, client := newTestRedis(t)
+
+	// miniredis does not expire keys by itself.
+	go func() {
+		for ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+			mr.FastForward(10 * time.Millisecond)
+		}
+	}()
+
+	var calls atomic.Int32
+	var tokens collector[int64]
+	job := JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		token, ok := FencingToken(ctx)
+		assert.True(t, ok)
+		tokens.Append(token)
+		return nil
+	})
+
+	a := NewDistributedRepeat(client, "repeat", 200*time.Millisecond, job)
+	b := NewDistributedRepeat(client, "repeat", 200*time.Millisecond, job)
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+	}()
+
+	err := RunAllWorkers(ctx, a, b)
+	require.NoError(t, err)
+
+	// The job runs at t=0ms, ~200ms and ~400ms regardless of the number of
+	// replicas.
+	assert.GreaterOrEqual(t, calls.Load(), int32(2))
+	assert.LessOrEqual(t, calls.Load(), int32(3))
+	assert.IsIncreasing(t, tokens.Result())
+}
+
+func TestDistributedRepeatCancelsJobOnLockLoss(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mr, client := newTestRedis(t)
+
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	job := JobFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cancelled.Store(true)
+		cancel()
+		return ctx.Err()
+	})
+
+	w := NewDistributedRepeat(client, "repeat", 200*time.Millisecond, job)
+
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	<-started
+	mr.Set("repeat", "someone-else")
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("job was not cancelled after lock loss")
+	}
+
+	assert.True(t, cancelled.Load())
+}
+
+func TestDistributedRepeatReleaseOnCompletion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mr, client := newTestRedis(t)
+
+	job := JobFunc(func(ctx context.Context) error {
+		cancel()
+		return nil
+	})
+
+	w := NewDistributedRepeat(client, "repeat", time.Minute, job, WithReleaseOnCompletion())
+	require.NoError(t, w.Run(ctx))
+
+	assert.False(t, mr.Exists("repeat"))
+}
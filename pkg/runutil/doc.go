@@ -37,18 +37,59 @@
 //	    return runutil.RunProvidedWorkers(ctx, c)
 //	}
 //
+// A worker can depend on another one, that needs time to become ready (eg
+// migrations or a cache warmup). The dependency implements [Readier], for
+// example by embedding a [ReadySignal], and gets injected into the dependent
+// worker. [RunProvidedWorkers] detects this and starts the dependent worker
+// only after the dependency is ready. The resulting graph is available with
+// [WorkerGraph] and [DescribeProvidedWorkers]. The cmdutil package provides
+// a worker-graph subcommand, that prints it without starting the workers.
+//
 // ## Retry and Backoff
 //
 // The runutil package provides utilities for retrying operations with backoff:
 //
 //	func FetchData(ctx context.Context) error {
-//	    return runutil.Retry(ctx, func(ctx context.Context) error {
+//	    job := runutil.RetryJob(runutil.JobFunc(func(ctx context.Context) error {
 //	        // Operation that might fail
 //	        return apiClient.FetchData(ctx)
-//	    },
+//	    }),
+//	        runutil.ExponentialBackoff{Initial: time.Second, Max: 30 * time.Second},
 //	        runutil.WithMaxAttempts(5),
-//	        runutil.WithBackoff(runutil.ExponentialBackoff(time.Second, 30*time.Second)),
 //	        runutil.WithRetryableErrors(ErrTemporary, ErrTimeout),
 //	    )
+//
+//	    return job.RunOnce(ctx)
 //	}
+//
+// Errors that should never be retried can be marked with [Permanent].
+//
+// ## Circuit Breaker
+//
+// A [CircuitBreaker] stops calling a failing dependency for a while, instead
+// of hammering it with retries:
+//
+//	cb := runutil.NewCircuitBreaker("payment-api",
+//	    runutil.WithConsecutiveFailures(5),
+//	    runutil.WithFailureRatio(0.5, 20),
+//	)
+//
+//	err := cb.Do(ctx, apiClient.FetchData)
+//	if errors.Is(err, runutil.ErrCircuitOpen) {
+//	    // The call was rejected without contacting the API.
+//	}
+//
+// ## Testing Schedules
+//
+// The scheduling workers take the time from the [Clock] of their context.
+// The package fakeclock provides a clock that only moves when the test
+// advances it, so schedules can be tested without real sleeps:
+//
+//	clock := fakeclock.New(time.Now())
+//	ctx = fakeclock.WithContext(ctx, clock, 42)
+//
+//	go runutil.Repeat(time.Hour, job).Run(ctx)
+//
+//	clock.BlockUntil(1)
+//	clock.Advance(time.Hour) // runs the job once
 package runutil
\ No newline at end of file
@@ -0,0 +1,238 @@
+package fakeclock
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+)
+
+// Clock is a [runutil.Clock] that only moves when [Clock.Advance] or
+// [Clock.Set] gets called. It is safe for concurrent use. Create it with
+// [New].
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// New creates a [Clock] that starts at the given time.
+func New(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// WithContext returns a context that makes the workers of runutil use the
+// given clock and a random source with the given seed for jitter.
+func WithContext(ctx context.Context, c *Clock, seed int64) context.Context {
+	ctx = runutil.WithClock(ctx, c)
+	ctx = runutil.WithRandSource(ctx, NewRand(seed))
+	return ctx
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *Clock) NewTimer(d time.Duration) runutil.Timer {
+	w := &waiter{clock: c, ch: make(chan time.Time, 1)}
+	w.Reset(d)
+	return w
+}
+
+// NewTicker creates a ticker that fires every d. Like [time.NewTicker], it
+// panics if d is not positive. As with a real ticker, ticks get dropped if the
+// receiver does not keep up.
+func (c *Clock) NewTicker(d time.Duration) runutil.Ticker {
+	if d <= 0 {
+		panic("fakeclock: non-positive interval for NewTicker")
+	}
+
+	w := &waiter{clock: c, ch: make(chan time.Time, 1)}
+	w.reset(d, d)
+	return tickerWaiter{w}
+}
+
+// Advance moves the clock forward by d and fires all timers and tickers that
+// are due in the meantime, in the order of their due time.
+//
+// Timers that get created as reaction to a fired timer are relative to the
+// time after Advance returned. Use multiple smaller steps together with
+// [Clock.BlockUntil] to test a sequence of waits.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to the given time and fires all timers and tickers that
+// are due until then. Setting the clock backwards does not fire anything.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		w := c.next()
+		if w == nil || w.when.After(t) {
+			break
+		}
+
+		if w.when.After(c.now) {
+			c.now = w.when
+		}
+
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			c.remove(w)
+		}
+	}
+
+	c.now = t
+}
+
+// Waiters returns the number of pending timers and tickers.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until there are at least n pending timers and tickers. This
+// is the way to wait until a worker in another goroutine started waiting for
+// the clock.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// next returns the waiter with the earliest due time or nil, if there is none.
+// The caller must hold the lock.
+func (c *Clock) next() *waiter {
+	var result *waiter
+	for _, w := range c.waiters {
+		if result == nil || w.when.Before(result.when) {
+			result = w
+		}
+	}
+	return result
+}
+
+// remove removes the waiter and returns true, if it was pending. The caller
+// must hold the lock.
+func (c *Clock) remove(w *waiter) bool {
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type waiter struct {
+	clock  *Clock
+	ch     chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+func (w *waiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *waiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	return w.clock.remove(w)
+}
+
+func (w *waiter) Reset(d time.Duration) bool {
+	return w.reset(d, 0)
+}
+
+// reset (re-)schedules the waiter to fire after d and then every period, if
+// period is positive. A timer that is already due fires immediately.
+func (w *waiter) reset(d, period time.Duration) bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	active := c.remove(w)
+	w.when = c.now.Add(d)
+	w.period = period
+
+	if d <= 0 && period <= 0 {
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		return active
+	}
+
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+	return active
+}
+
+type tickerWaiter struct {
+	w *waiter
+}
+
+func (t tickerWaiter) C() <-chan time.Time { return t.w.ch }
+func (t tickerWaiter) Stop()               { t.w.Stop() }
+
+func (t tickerWaiter) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("fakeclock: non-positive interval for Ticker.Reset")
+	}
+
+	t.w.reset(d, d)
+}
+
+// Rand is a [runutil.RandSource] with a fixed seed. It is safe for concurrent
+// use, but the sequence of values is only reproducible, if the callers are
+// deterministic as well.
+type Rand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// NewRand creates a [Rand] with the given seed.
+func NewRand(seed int64) *Rand {
+	return &Rand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (r *Rand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Float64()
+}
+
+func (r *Rand) Int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Int63n(n)
+}
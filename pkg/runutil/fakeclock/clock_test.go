@@ -0,0 +1,359 @@
+package fakeclock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil/fakeclock"
+)
+
+var start = time.Date(2025, 1, 1, 0, 0, 30, 0, time.UTC)
+
+func receive(t *testing.T, ch <-chan time.Time) time.Time {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for value")
+		return time.Time{}
+	}
+}
+
+func assertEmpty(t *testing.T, ch <-chan time.Time) {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		t.Fatalf("unexpected value %v", v)
+	default:
+	}
+}
+
+func TestTimer(t *testing.T) {
+	clock := fakeclock.New(start)
+	timer := clock.NewTimer(time.Minute)
+
+	clock.Advance(59 * time.Second)
+	assertEmpty(t, timer.C())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Minute), receive(t, timer.C()))
+	assert.Equal(t, 0, clock.Waiters())
+
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Hour)
+	assertEmpty(t, timer.C())
+
+	assert.Equal(t, start.Add(time.Hour+time.Minute), clock.Now())
+}
+
+func TestAfterZero(t *testing.T) {
+	clock := fakeclock.New(start)
+	assert.Equal(t, start, receive(t, clock.After(0)))
+}
+
+func TestTicker(t *testing.T) {
+	clock := fakeclock.New(start)
+	ticker := clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, start.Add(10*time.Second), receive(t, ticker.C()))
+
+	// Ticks are dropped, if the receiver does not keep up.
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(20*time.Second), receive(t, ticker.C()))
+	assertEmpty(t, ticker.C())
+
+	ticker.Reset(time.Minute)
+	clock.Advance(59 * time.Second)
+	assertEmpty(t, ticker.C())
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(100*time.Second), receive(t, ticker.C()))
+}
+
+func TestRand(t *testing.T) {
+	a, b := fakeclock.NewRand(42), fakeclock.NewRand(42)
+	for range 10 {
+		assert.Equal(t, a.Int63n(1000), b.Int63n(1000))
+	}
+
+	backoff := func() runutil.Backoff {
+		return runutil.ExponentialBackoff{
+			Initial:          time.Second,
+			Max:              time.Minute,
+			JitterProportion: 0.5,
+			Rand:             fakeclock.NewRand(7),
+		}
+	}
+
+	first, second := backoff(), backoff()
+	for i := range 10 {
+		assert.Equal(t, first.Duration(i), second.Duration(i))
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	clock := fakeclock.New(start)
+	ctx = fakeclock.WithContext(ctx, clock, 1)
+
+	runs := make(chan time.Time)
+	worker := runutil.Repeat(time.Hour, runutil.JobFunc(func(ctx context.Context) error {
+		runs <- runutil.ClockFromContext(ctx).Now()
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Hour)
+		assert.Equal(t, start.Add(time.Duration(i)*time.Hour), receive(t, runs))
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestCron(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	start := start.Truncate(time.Hour)
+	clock := fakeclock.New(start)
+	ctx = fakeclock.WithContext(ctx, clock, 1)
+
+	runs := make(chan time.Time)
+	worker := runutil.Cron("0 * * * *", runutil.JobFunc(func(ctx context.Context) error {
+		runs <- runutil.ClockFromContext(ctx).Now()
+		return nil
+	}), runutil.WithCronLocation(time.UTC))
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	for i := 1; i <= 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+		assert.Equal(t, start.Add(time.Duration(i)*time.Hour), receive(t, runs))
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestRetryJob(t *testing.T) {
+	clock := fakeclock.New(start)
+	ctx := fakeclock.WithContext(t.Context(), clock, 1)
+
+	errTest := errors.New("test")
+
+	var attempts []time.Time
+	job := runutil.RetryJob(runutil.JobFunc(func(ctx context.Context) error {
+		attempts = append(attempts, runutil.ClockFromContext(ctx).Now())
+		return errTest
+	}), runutil.StaticBackoff{Sleep: time.Minute}, runutil.WithMaxAttempts(3))
+
+	done := make(chan error)
+	go func() { done <- job.RunOnce(ctx) }()
+
+	for range 2 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+	}
+
+	err := <-done
+	require.ErrorIs(t, err, runutil.ErrRetriesExhausted)
+	require.ErrorIs(t, err, errTest)
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(time.Minute),
+		start.Add(2 * time.Minute),
+	}, attempts)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := t.Context()
+	clock := fakeclock.New(start)
+	errFail := errors.New("fail")
+
+	cb := runutil.NewCircuitBreaker("test-consecutive",
+		runutil.WithConsecutiveFailures(3),
+		runutil.WithOpenBackoff(runutil.StaticBackoff{Sleep: time.Minute}),
+		runutil.WithCircuitBreakerClock(clock),
+	)
+
+	var calls int
+	fn := func(context.Context) error {
+		calls++
+		return errFail
+	}
+
+	for range 3 {
+		err := cb.Do(ctx, fn)
+		require.ErrorIs(t, err, errFail)
+	}
+	require.Equal(t, runutil.CircuitStateOpen, cb.State())
+
+	err := cb.Do(ctx, fn)
+	require.ErrorIs(t, err, runutil.ErrCircuitOpen)
+	require.Equal(t, 3, calls)
+
+	var openErr *runutil.CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, "test-consecutive", openErr.Name)
+	assert.Equal(t, start.Add(time.Minute), openErr.Until)
+	assert.Equal(t, time.Minute, openErr.RetryAfter())
+
+	clock.Advance(time.Minute - time.Second)
+	require.Equal(t, runutil.CircuitStateOpen, cb.State())
+	assert.Equal(t, time.Second, openErr.RetryAfter())
+
+	clock.Advance(time.Second)
+	require.Equal(t, runutil.CircuitStateHalfOpen, cb.State())
+
+	// A failing probe opens the breaker again.
+	err = cb.Do(ctx, fn)
+	require.ErrorIs(t, err, errFail)
+	require.Equal(t, runutil.CircuitStateOpen, cb.State())
+
+	clock.Advance(time.Minute)
+	err = cb.Do(ctx, func(context.Context) error { return nil })
+	require.NoError(t, err)
+	require.Equal(t, runutil.CircuitStateClosed, cb.State())
+}
+
+func TestTokenBucket(t *testing.T) {
+	ctx := t.Context()
+	clock := fakeclock.New(start)
+
+	bucket := runutil.NewTokenBucket(1, 2, runutil.WithRateLimiterClock(clock))
+
+	for range 2 {
+		require.NoError(t, bucket.Wait(ctx))
+	}
+
+	done := make(chan error)
+	go func() { done <- bucket.Wait(ctx) }()
+
+	clock.BlockUntil(1)
+	select {
+	case <-done:
+		t.Fatal("bucket did not throttle")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	require.NoError(t, <-done)
+}
+
+func healthStatus(t *testing.T, name string) runutil.HealthStatus {
+	t.Helper()
+
+	for _, status := range runutil.HealthReport() {
+		if status.Name == name {
+			return status
+		}
+	}
+
+	t.Fatalf("no health status for %s", name)
+	return runutil.HealthStatus{}
+}
+
+func TestHealthStaleness(t *testing.T) {
+	clock := fakeclock.New(start)
+	ctx := fakeclock.WithContext(t.Context(), clock, 1)
+	ctx = logutil.Start(ctx, "fakeclock-health")
+	name := logutil.GetSubsystem(ctx)
+
+	runutil.HealthCheckpoint(ctx, nil)
+	status := healthStatus(t, name)
+	assert.Equal(t, start, status.Since)
+	assert.Equal(t, start, status.LastCheckpoint)
+
+	clock.Advance(30 * time.Second)
+	require.NoError(t, healthStatus(t, name).Ready(clock.Now(), time.Minute))
+
+	clock.Advance(time.Minute)
+	require.Error(t, healthStatus(t, name).Ready(clock.Now(), time.Minute))
+	require.ErrorContains(t, runutil.HealthReady(ctx, time.Minute), name)
+
+	runutil.HealthCheckpoint(ctx, nil)
+	assert.Equal(t, start.Add(90*time.Second), healthStatus(t, name).LastCheckpoint)
+	require.NoError(t, healthStatus(t, name).Ready(clock.Now(), time.Minute))
+}
+
+// jobMetric returns the job metric with the given name of the given worker.
+func jobMetric(t *testing.T, name, worker string) *dto.Metric {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "worker_name" && label.GetValue() == worker {
+					return metric
+				}
+			}
+		}
+	}
+
+	t.Fatalf("no metric %s for %s", name, worker)
+	return nil
+}
+
+func TestJobDuration(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	clock := fakeclock.New(start)
+	ctx = fakeclock.WithContext(ctx, clock, 1)
+	ctx = logutil.Start(ctx, "fakeclock-job")
+	name := logutil.GetSubsystem(ctx)
+
+	runs := make(chan time.Time)
+	worker := runutil.Repeat(time.Hour, runutil.JobFunc(func(ctx context.Context) error {
+		clock.Advance(5 * time.Second)
+		runs <- runutil.ClockFromContext(ctx).Now()
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	finished := receive(t, runs)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	duration := jobMetric(t, "rebuy_go_sdk_job_duration_seconds", name).GetHistogram()
+	assert.Equal(t, uint64(1), duration.GetSampleCount())
+	assert.Equal(t, 5., duration.GetSampleSum())
+
+	last := jobMetric(t, "rebuy_go_sdk_job_last_success_timestamp_seconds", name).GetGauge()
+	assert.Equal(t, float64(finished.UnixMilli())/1000, last.GetValue())
+}
@@ -0,0 +1,21 @@
+// Package fakeclock provides a deterministic [runutil.Clock] for tests.
+//
+// The fake clock only moves when the test advances it. This way schedules of
+// workers like [runutil.Repeat], [runutil.Cron] or [runutil.RetryJob] can be
+// tested in milliseconds instead of waiting for real sleeps.
+//
+// Usage:
+//
+//	clock := fakeclock.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+//	ctx = fakeclock.WithContext(ctx, clock, 42)
+//
+//	go runutil.Repeat(time.Minute, job).Run(ctx)
+//
+//	clock.BlockUntil(1) // wait until the worker created its ticker
+//	clock.Advance(time.Minute)
+//
+// Workers run in their own goroutines, therefore tests have to synchronize
+// with them. [Clock.BlockUntil] waits until a given number of timers and
+// tickers is pending, which usually means the worker is waiting for the next
+// run.
+package fakeclock
@@ -0,0 +1,367 @@
+package runutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"go.uber.org/dig"
+)
+
+// Readier is implemented by a [WorkerConfiger] that needs time to become
+// ready, eg because it runs migrations or warms up a cache. See [ReadySignal]
+// for a simple implementation.
+//
+// [RunProvidedWorkers] starts the workers of a configer, that references a
+// Readier configer in one of its struct fields, only after the Readier is
+// ready. This is the case, when the Readier got injected into the dependent
+// configer by dig.
+type Readier interface {
+	Ready() <-chan struct{}
+}
+
+// ReadySignal implements [Readier]. It can be embedded into a
+// [WorkerConfiger]. The zero value is not ready.
+type ReadySignal struct {
+	initOnce  sync.Once
+	readyOnce sync.Once
+	ch        chan struct{}
+}
+
+func (s *ReadySignal) init() {
+	s.initOnce.Do(func() {
+		s.ch = make(chan struct{})
+	})
+}
+
+// Ready returns a channel that gets closed by [ReadySignal.SetReady].
+func (s *ReadySignal) Ready() <-chan struct{} {
+	s.init()
+	return s.ch
+}
+
+// SetReady marks the signal as ready. Subsequent calls are no-ops.
+func (s *ReadySignal) SetReady() {
+	s.init()
+	s.readyOnce.Do(func() {
+		close(s.ch)
+	})
+}
+
+// WorkerNode describes a [WorkerConfiger] in the worker graph. See
+// [DescribeProvidedWorkers] and [WorkerGraph]. The name is the type name of the
+// configer, with an index suffix (eg "#2"), if there are multiple configers of
+// the same type.
+type WorkerNode struct {
+	Name      string   `json:"name"`
+	Workers   []string `json:"workers"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	Readier   bool     `json:"readier"`
+	Ready     bool     `json:"ready"`
+}
+
+var workerGraphRegistry struct {
+	mu    sync.Mutex
+	graph *workerGraph
+}
+
+// WorkerGraph returns the worker graph of the last call of
+// [RunProvidedWorkers], including the current readiness. It returns nil, if
+// the workers were not started yet.
+func WorkerGraph() []WorkerNode {
+	workerGraphRegistry.mu.Lock()
+	graph := workerGraphRegistry.graph
+	workerGraphRegistry.mu.Unlock()
+
+	if graph == nil {
+		return nil
+	}
+
+	return graph.describe()
+}
+
+// DescribeProvidedWorkers returns the graph of the injected workers without
+// starting them. This is useful to print the graph from a CLI command. Note
+// that this instantiates all workers with dig.
+func DescribeProvidedWorkers(c *dig.Container) ([]WorkerNode, error) {
+	var result []WorkerNode
+	err := c.Invoke(func(in WorkerGroup) error {
+		graph, err := newWorkerGraph(in.All)
+		if err != nil {
+			return err
+		}
+
+		result = graph.describe()
+		return nil
+	})
+	return result, err
+}
+
+// WorkerGraphDOT renders the worker graph in the Graphviz DOT format. Readiers
+// are drawn as boxes and the edges point from a node to its dependencies.
+func WorkerGraphDOT(nodes []WorkerNode) string {
+	var b strings.Builder
+
+	b.WriteString("digraph workers {\n")
+	for _, n := range nodes {
+		shape := "ellipse"
+		if n.Readier {
+			shape = "box"
+		}
+
+		label := strings.Join(append([]string{n.Name}, n.Workers...), "\\n")
+		fmt.Fprintf(&b, "\t%q [shape=%s, label=%q];\n", n.Name, shape, label)
+	}
+	for _, n := range nodes {
+		for _, dep := range n.DependsOn {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", n.Name, dep)
+		}
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+type workerGraph struct {
+	nodes []*workerGraphNode
+}
+
+type workerGraphNode struct {
+	name     string
+	configer WorkerConfiger
+	readier  Readier
+	children []ChildSpec
+	deps     []*workerGraphNode
+}
+
+// newWorkerGraph builds the graph of the configers. A configer depends on
+// another one, if one of its struct fields points to it. It returns an error,
+// if the dependencies contain a cycle.
+func newWorkerGraph(configers []WorkerConfiger) (*workerGraph, error) {
+	type identity struct {
+		t reflect.Type
+		p uintptr
+	}
+
+	graph := new(workerGraph)
+	byIdentity := map[identity]*workerGraphNode{}
+
+	// Configers of the same type get an index suffix, so the node names
+	// stay unique.
+	typeCounts := map[string]int{}
+	for _, c := range configers {
+		if c != nil {
+			typeCounts[typeName(c)]++
+		}
+	}
+	typeIndexes := map[string]int{}
+
+	for _, c := range configers {
+		if c == nil {
+			continue
+		}
+
+		name := typeName(c)
+		if typeCounts[name] > 1 {
+			typeIndexes[name]++
+			name = fmt.Sprintf("%s#%d", name, typeIndexes[name])
+		}
+
+		node := &workerGraphNode{
+			name:     name,
+			configer: c,
+		}
+		node.readier, _ = c.(Readier)
+
+		for _, w := range c.Workers() {
+			// The spec must be extracted before naming the worker,
+			// because the name wrapper hides the DeclarativeWorker.
+			child := childSpecOf(w)
+			child.Worker = w
+			child.Name = strings.TrimSuffix(node.name+"/"+child.Name, "/")
+			node.children = append(node.children, child)
+		}
+
+		v := reflect.ValueOf(c)
+		if v.Kind() == reflect.Pointer {
+			byIdentity[identity{v.Type(), v.Pointer()}] = node
+		}
+
+		graph.nodes = append(graph.nodes, node)
+	}
+
+	for _, node := range graph.nodes {
+		forEachPointerField(reflect.ValueOf(node.configer), func(f reflect.Value) {
+			dep, ok := byIdentity[identity{f.Type(), f.Pointer()}]
+			if ok && dep != node && !slices.Contains(node.deps, dep) {
+				node.deps = append(node.deps, dep)
+			}
+		})
+	}
+
+	err := graph.checkCycles()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph, nil
+}
+
+// forEachPointerField calls fn for each non-nil pointer in the fields of the
+// struct v points to. Interfaces are unwrapped and embedded structs are
+// searched as well.
+func forEachPointerField(v reflect.Value, fn func(reflect.Value)) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if v.Type().Field(i).Anonymous && f.Kind() == reflect.Struct {
+			forEachPointerField(f, fn)
+			continue
+		}
+
+		if f.Kind() == reflect.Interface && !f.IsNil() {
+			f = f.Elem()
+		}
+
+		if f.Kind() == reflect.Pointer && !f.IsNil() {
+			fn(f)
+		}
+	}
+}
+
+func (g *workerGraph) checkCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[*workerGraphNode]int{}
+	var path []string
+
+	var visit func(n *workerGraphNode) error
+	visit = func(n *workerGraphNode) error {
+		switch state[n] {
+		case visited:
+			return nil
+		case visiting:
+			start := slices.Index(path, n.name)
+			return fmt.Errorf("worker dependency cycle: %s",
+				strings.Join(append(path[start:], n.name), " -> "))
+		}
+
+		state[n] = visiting
+		path = append(path, n.name)
+		for _, dep := range n.deps {
+			err := visit(dep)
+			if err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+
+		return nil
+	}
+
+	for _, n := range g.nodes {
+		err := visit(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *workerGraph) describe() []WorkerNode {
+	result := make([]WorkerNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		wn := WorkerNode{
+			Name:    n.name,
+			Workers: []string{},
+			Readier: n.readier != nil,
+			Ready:   n.readier == nil || isReady(n.readier),
+		}
+
+		for _, c := range n.children {
+			wn.Workers = append(wn.Workers, c.Name)
+		}
+
+		for _, dep := range n.deps {
+			wn.DependsOn = append(wn.DependsOn, dep.name)
+		}
+
+		result = append(result, wn)
+	}
+
+	return result
+}
+
+// childSpecs returns the specs of all workers. The workers get the subsystem
+// name of their configer and wait for the dependencies of their configer
+// before starting.
+func (g *workerGraph) childSpecs() []ChildSpec {
+	children := []ChildSpec{}
+	for _, n := range g.nodes {
+		for _, child := range n.children {
+			child.Worker = NamedWorkerFromType(n.waitForDependencies(child.Worker), n.configer)
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+func (n *workerGraphNode) waitForDependencies(worker Worker) Worker {
+	var readiers []*workerGraphNode
+	for _, dep := range n.deps {
+		if dep.readier != nil {
+			readiers = append(readiers, dep)
+		}
+	}
+
+	if len(readiers) == 0 {
+		return worker
+	}
+
+	return WorkerFunc(func(ctx context.Context) error {
+		for _, dep := range readiers {
+			if isReady(dep.readier) {
+				continue
+			}
+
+			logutil.Get(ctx).Info("waiting for dependency to become ready", "dependency", dep.name)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-dep.readier.Ready():
+			}
+		}
+
+		return worker.Run(ctx)
+	})
+}
+
+func isReady(r Readier) bool {
+	select {
+	case <-r.Ready():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,152 @@
+package runutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/dig"
+)
+
+type testMigrator struct {
+	ReadySignal
+	release chan struct{}
+}
+
+func (m *testMigrator) Workers() []Worker {
+	return []Worker{DeclarativeWorker{
+		Name: "migrate",
+		Worker: WorkerFunc(func(ctx context.Context) error {
+			select {
+			case <-m.release:
+			case <-ctx.Done():
+				return nil
+			}
+			m.SetReady()
+			<-ctx.Done()
+			return nil
+		}),
+	}}
+}
+
+type testFetcher struct {
+	migrator *testMigrator
+	started  chan bool
+}
+
+func (f *testFetcher) Workers() []Worker {
+	return []Worker{WorkerFunc(func(ctx context.Context) error {
+		f.started <- isReady(f.migrator)
+		<-ctx.Done()
+		return nil
+	})}
+}
+
+func newTestWorkerContainer(t *testing.T) *dig.Container {
+	t.Helper()
+
+	c := dig.New()
+	require.NoError(t, c.Provide(func() *testMigrator {
+		return &testMigrator{release: make(chan struct{})}
+	}))
+	require.NoError(t, ProvideWorker(c, func(m *testMigrator) *testMigrator { return m }))
+	require.NoError(t, ProvideWorker(c, func(m *testMigrator) *testFetcher {
+		return &testFetcher{migrator: m, started: make(chan bool, 1)}
+	}))
+	return c
+}
+
+func TestDescribeProvidedWorkers(t *testing.T) {
+	nodes, err := DescribeProvidedWorkers(newTestWorkerContainer(t))
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []WorkerNode{
+		{
+			Name:    "runutil/testMigrator",
+			Workers: []string{"runutil/testMigrator/migrate"},
+			Readier: true,
+		},
+		{
+			Name:      "runutil/testFetcher",
+			Workers:   []string{"runutil/testFetcher"},
+			DependsOn: []string{"runutil/testMigrator"},
+			Ready:     true,
+		},
+	}, nodes)
+
+	dot := WorkerGraphDOT(nodes)
+	assert.Contains(t, dot, `"runutil/testFetcher" -> "runutil/testMigrator";`)
+}
+
+func TestRunProvidedWorkersWaitsForReadiness(t *testing.T) {
+	c := newTestWorkerContainer(t)
+
+	var (
+		migrator *testMigrator
+		fetcher  *testFetcher
+	)
+	require.NoError(t, c.Invoke(func(m *testMigrator, in WorkerGroup) {
+		migrator = m
+		for _, w := range in.All {
+			if f, ok := w.(*testFetcher); ok {
+				fetcher = f
+			}
+		}
+	}))
+	require.NotNil(t, fetcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- RunProvidedWorkers(ctx, c) }()
+
+	select {
+	case <-fetcher.started:
+		t.Fatal("fetcher must not start before the migrator is ready")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(migrator.release)
+	assert.True(t, <-fetcher.started)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestWorkerGraphUniqueNames(t *testing.T) {
+	migrator := &testMigrator{}
+	graph, err := newWorkerGraph([]WorkerConfiger{
+		migrator,
+		&testFetcher{migrator: migrator},
+		&testFetcher{migrator: migrator},
+	})
+	require.NoError(t, err)
+
+	nodes := graph.describe()
+	require.Len(t, nodes, 3)
+	assert.Equal(t, "runutil/testMigrator", nodes[0].Name)
+	assert.Equal(t, "runutil/testFetcher#1", nodes[1].Name)
+	assert.Equal(t, []string{"runutil/testFetcher#1"}, nodes[1].Workers)
+	assert.Equal(t, "runutil/testFetcher#2", nodes[2].Name)
+	assert.Equal(t, []string{"runutil/testFetcher#2"}, nodes[2].Workers)
+
+	dot := WorkerGraphDOT(nodes)
+	assert.Contains(t, dot, `"runutil/testFetcher#1" -> "runutil/testMigrator";`)
+	assert.Contains(t, dot, `"runutil/testFetcher#2" -> "runutil/testMigrator";`)
+}
+
+type testCycleA struct{ b *testCycleB }
+type testCycleB struct{ a *testCycleA }
+
+func (*testCycleA) Workers() []Worker { return nil }
+func (*testCycleB) Workers() []Worker { return nil }
+
+func TestWorkerGraphCycle(t *testing.T) {
+	a := &testCycleA{}
+	b := &testCycleB{a: a}
+	a.b = b
+
+	_, err := newWorkerGraph([]WorkerConfiger{a, b})
+	require.ErrorContains(t, err, "worker dependency cycle: runutil/testCycleA -> runutil/testCycleB -> runutil/testCycleA")
+}
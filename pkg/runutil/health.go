@@ -2,17 +2,23 @@ package runutil
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 )
 
-const (
-	promNamespace       = "rebuy_go_sdk"
-	promHealthSubsystem = "health"
-)
+// PromNamespace is the namespace of the Prometheus metrics of the SDK. Other
+// packages of the SDK use it as well, so all metrics share the same prefix.
+const PromNamespace = "rebuy_go_sdk"
+
+const promHealthSubsystem = "health"
 
 const (
 	HealthStateInit   = "init"
@@ -22,13 +28,13 @@ const (
 
 var (
 	instHealthCheckpointsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: promNamespace,
+		Namespace: PromNamespace,
 		Subsystem: promHealthSubsystem,
 		Name:      "checkpoints_total",
 	}, []string{"worker_name", "state"})
 
 	instHealthState = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: promNamespace,
+		Namespace: PromNamespace,
 		Subsystem: promHealthSubsystem,
 		Name:      "state",
 	}, []string{"worker_name", "state"})
@@ -38,54 +44,191 @@ var healthRegistry = &healthRegistryImpl{
 	monitors: map[string]*healthMonitor{},
 }
 
-// HealthMonitor tracks the health state of a worker via Prometheus metrics.
+// HealthMonitor tracks the health state of a worker via Prometheus metrics and
+// keeps the latest state for [HealthReport].
 type HealthMonitor interface {
 	Checkpoint(err error)
 }
 
 // HealthCheckpoint records a health checkpoint for the current subsystem.
-// It is a no-op if the context has no subsystem set.
+// It is a no-op if the context has no subsystem set. The time of the
+// checkpoint is taken from the [Clock] of the context.
 func HealthCheckpoint(ctx context.Context, err error) {
 	name := logutil.GetSubsystem(ctx)
 	if name == "" {
 		return
 	}
 
-	healthRegistry.get(name).Checkpoint(err)
+	healthRegistry.get(name, ClockFromContext(ctx)).Checkpoint(err)
 }
 
 // GetHealthMonitor returns the health monitor for the current subsystem.
-// Useful for long-running workers that need to pass a monitor to helpers.
+// Useful for long-running workers that need to pass a monitor to helpers. The
+// monitor uses the [Clock] of the context.
 func GetHealthMonitor(ctx context.Context) HealthMonitor {
 	name := logutil.GetSubsystem(ctx)
 	if name == "" {
 		return (*healthMonitor)(nil)
 	}
 
-	return healthRegistry.get(name)
+	return healthRegistry.get(name, ClockFromContext(ctx))
+}
+
+// HealthStatus is a snapshot of the health state of a single worker.
+// Informational monitors (eg of a [CircuitBreaker]) are part of the
+// [HealthReport], but never affect the readiness.
+type HealthStatus struct {
+	Name           string    `json:"name"`
+	State          string    `json:"state"`
+	Since          time.Time `json:"since"`
+	LastCheckpoint time.Time `json:"last_checkpoint,omitzero"`
+	LastError      string    `json:"last_error,omitempty"`
+	Informational  bool      `json:"informational,omitempty"`
+}
+
+// Stale returns true, if the worker did checkpoint at least once, but not
+// within the given window. Workers that never checkpointed are not considered
+// stale, because long-running workers (like HTTP servers) do not checkpoint at
+// all. A window of zero disables the check.
+func (s HealthStatus) Stale(now time.Time, window time.Duration) bool {
+	if window <= 0 || s.LastCheckpoint.IsZero() {
+		return false
+	}
+
+	return now.Sub(s.LastCheckpoint) > window
+}
+
+// Ready returns an error describing why the worker is not ready, or nil if it
+// is ready. A worker is not ready if it is in firing state or if it is stale.
+// Informational monitors are always ready.
+func (s HealthStatus) Ready(now time.Time, staleness time.Duration) error {
+	if s.Informational {
+		return nil
+	}
+
+	if s.State == HealthStateFiring {
+		return fmt.Errorf("worker %s is firing: %s", s.Name, s.LastError)
+	}
+
+	if s.Stale(now, staleness) {
+		return fmt.Errorf("worker %s did not checkpoint since %s",
+			s.Name, now.Sub(s.LastCheckpoint).Truncate(time.Second))
+	}
+
+	return nil
+}
+
+// HealthReport returns the current health state of all known workers, sorted
+// by name. Workers that were started with [NamedWorker] (eg by
+// [RunProvidedWorkers]) are removed after they returned.
+func HealthReport() []HealthStatus {
+	return healthRegistry.report()
+}
+
+// HealthReady checks all known workers with [HealthStatus.Ready] and returns
+// all errors joined. It returns nil, if all workers are ready. The staleness
+// is checked with the [Clock] of the context.
+func HealthReady(ctx context.Context, staleness time.Duration) error {
+	now := ClockFromContext(ctx).Now()
+
+	var errs []error
+	for _, status := range HealthReport() {
+		errs = append(errs, status.Ready(now, staleness))
+	}
+
+	return errors.Join(errs...)
 }
 
 type healthRegistryImpl struct {
 	monitors map[string]*healthMonitor
+	running  map[string]int
 	mu       sync.Mutex
 }
 
-func (r *healthRegistryImpl) get(name string) *healthMonitor {
+func (r *healthRegistryImpl) report() []HealthStatus {
+	r.mu.Lock()
+	monitors := make([]*healthMonitor, 0, len(r.monitors))
+	for _, m := range r.monitors {
+		monitors = append(monitors, m)
+	}
+	r.mu.Unlock()
+
+	result := make([]HealthStatus, 0, len(monitors))
+	for _, m := range monitors {
+		result = append(result, m.status())
+	}
+
+	slices.SortFunc(result, func(a, b HealthStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
+// get returns the monitor with the given name and creates it, if it does not
+// exist yet. The clock is used for the timestamps of the monitor.
+func (r *healthRegistryImpl) get(name string, clock Clock) *healthMonitor {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	monitor, ok := r.monitors[name]
 	if !ok {
-		monitor = newHealthMonitor(name)
+		monitor = newHealthMonitor(name, clock)
 		r.monitors[name] = monitor
 	}
 
 	return monitor
 }
 
-func newHealthMonitor(name string) *healthMonitor {
+// getInformational returns the monitor with the given name like get, but marks
+// it as informational, so it does not affect the readiness.
+func (r *healthRegistryImpl) getInformational(name string, clock Clock) *healthMonitor {
+	monitor := r.get(name, clock)
+
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	monitor.informational = true
+
+	return monitor
+}
+
+// startWorker marks a worker with the given subsystem name as running. The
+// returned function must be called, when the worker returned. After the last
+// worker of a name returned, its monitor gets removed, so finished or renamed
+// workers cannot keep [HealthReady] failing.
+func (r *healthRegistryImpl) startWorker(name string) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running == nil {
+		r.running = map[string]int{}
+	}
+	r.running[name]++
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.running[name]--
+		if r.running[name] > 0 {
+			return
+		}
+		delete(r.running, name)
+
+		monitor, ok := r.monitors[name]
+		if ok {
+			delete(r.monitors, name)
+			monitor.deleteMetrics()
+		}
+	}
+}
+
+func newHealthMonitor(name string, clock Clock) *healthMonitor {
 	m := &healthMonitor{
-		name: name,
+		name:  name,
+		clock: clock,
+		state: HealthStateInit,
+		since: clock.Now(),
 	}
 
 	for _, state := range []string{HealthStateInit, HealthStateOK, HealthStateFiring} {
@@ -106,7 +249,16 @@ func newHealthMonitor(name string) *healthMonitor {
 }
 
 type healthMonitor struct {
-	name string
+	name  string
+	clock Clock
+
+	informational bool
+
+	mu             sync.Mutex
+	state          string
+	since          time.Time
+	lastCheckpoint time.Time
+	lastError      error
 }
 
 func (m *healthMonitor) Checkpoint(err error) {
@@ -114,6 +266,8 @@ func (m *healthMonitor) Checkpoint(err error) {
 		return
 	}
 
+	m.record(err)
+
 	if err == nil {
 		m.resolve()
 	} else {
@@ -121,6 +275,43 @@ func (m *healthMonitor) Checkpoint(err error) {
 	}
 }
 
+func (m *healthMonitor) record(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := HealthStateOK
+	if err != nil {
+		state = HealthStateFiring
+	}
+
+	now := m.clock.Now()
+	if m.state != state {
+		m.state = state
+		m.since = now
+	}
+	m.lastCheckpoint = now
+	m.lastError = err
+}
+
+func (m *healthMonitor) status() HealthStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := HealthStatus{
+		Name:           m.name,
+		State:          m.state,
+		Since:          m.since,
+		LastCheckpoint: m.lastCheckpoint,
+		Informational:  m.informational,
+	}
+
+	if m.lastError != nil {
+		status.LastError = m.lastError.Error()
+	}
+
+	return status
+}
+
 func (m *healthMonitor) resolve() {
 	instHealthCheckpointsTotal.
 		WithLabelValues(m.name, HealthStateOK).
@@ -150,3 +341,10 @@ func (m *healthMonitor) fire() {
 		WithLabelValues(m.name, HealthStateFiring).
 		Set(1)
 }
+
+func (m *healthMonitor) deleteMetrics() {
+	for _, state := range []string{HealthStateInit, HealthStateOK, HealthStateFiring} {
+		instHealthCheckpointsTotal.DeleteLabelValues(m.name, state)
+		instHealthState.DeleteLabelValues(m.name, state)
+	}
+}
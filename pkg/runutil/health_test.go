@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 	"github.com/stretchr/testify/assert"
@@ -14,8 +15,8 @@ func TestHealthRegistry_ReturnsSameMonitor(t *testing.T) {
 		monitors: map[string]*healthMonitor{},
 	}
 
-	m1 := r.get("test-worker")
-	m2 := r.get("test-worker")
+	m1 := r.get("test-worker", realClock{})
+	m2 := r.get("test-worker", realClock{})
 
 	assert.Same(t, m1, m2)
 }
@@ -25,8 +26,8 @@ func TestHealthRegistry_DifferentNames(t *testing.T) {
 		monitors: map[string]*healthMonitor{},
 	}
 
-	m1 := r.get("worker-a")
-	m2 := r.get("worker-b")
+	m1 := r.get("worker-a", realClock{})
+	m2 := r.get("worker-b", realClock{})
 
 	assert.NotSame(t, m1, m2)
 }
@@ -76,3 +77,135 @@ func TestHealthMonitor_NilCheckpoint(t *testing.T) {
 	m.Checkpoint(nil)
 	m.Checkpoint(errors.New("test"))
 }
+
+func TestHealthMonitor_Status(t *testing.T) {
+	m := newHealthMonitor("test-status", realClock{})
+
+	status := m.status()
+	assert.Equal(t, "test-status", status.Name)
+	assert.Equal(t, HealthStateInit, status.State)
+	assert.True(t, status.LastCheckpoint.IsZero())
+
+	m.Checkpoint(errors.New("broken"))
+	status = m.status()
+	assert.Equal(t, HealthStateFiring, status.State)
+	assert.Equal(t, "broken", status.LastError)
+	assert.False(t, status.LastCheckpoint.IsZero())
+
+	m.Checkpoint(nil)
+	status = m.status()
+	assert.Equal(t, HealthStateOK, status.State)
+	assert.Empty(t, status.LastError)
+}
+
+func TestHealthStatus_Ready(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name      string
+		status    HealthStatus
+		staleness time.Duration
+		ready     bool
+	}{
+		{
+			name:   "init",
+			status: HealthStatus{State: HealthStateInit},
+			ready:  true,
+		},
+		{
+			name:      "init-never-stale",
+			status:    HealthStatus{State: HealthStateInit},
+			staleness: time.Minute,
+			ready:     true,
+		},
+		{
+			name:   "firing",
+			status: HealthStatus{State: HealthStateFiring, LastCheckpoint: now},
+			ready:  false,
+		},
+		{
+			name:      "ok-fresh",
+			status:    HealthStatus{State: HealthStateOK, LastCheckpoint: now.Add(-30 * time.Second)},
+			staleness: time.Minute,
+			ready:     true,
+		},
+		{
+			name:      "ok-stale",
+			status:    HealthStatus{State: HealthStateOK, LastCheckpoint: now.Add(-2 * time.Minute)},
+			staleness: time.Minute,
+			ready:     false,
+		},
+		{
+			name:   "ok-stale-disabled",
+			status: HealthStatus{State: HealthStateOK, LastCheckpoint: now.Add(-2 * time.Minute)},
+			ready:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.status.Ready(now, tc.staleness)
+			if tc.ready {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestHealthRegistry_ReportSorted(t *testing.T) {
+	r := &healthRegistryImpl{
+		monitors: map[string]*healthMonitor{},
+	}
+
+	r.get("worker-b", realClock{}).Checkpoint(nil)
+	r.get("worker-a", realClock{}).Checkpoint(errors.New("test"))
+
+	report := r.report()
+	assert.Len(t, report, 2)
+	assert.Equal(t, "worker-a", report[0].Name)
+	assert.Equal(t, HealthStateFiring, report[0].State)
+	assert.Equal(t, "worker-b", report[1].Name)
+	assert.Equal(t, HealthStateOK, report[1].State)
+}
+
+func healthReportContains(name string) bool {
+	for _, status := range HealthReport() {
+		if status.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNamedWorker_RemovesHealthMonitor(t *testing.T) {
+	var (
+		started = make(chan struct{}, 2)
+		release = make(chan struct{})
+	)
+
+	worker := NamedWorker(WorkerFunc(func(ctx context.Context) error {
+		HealthCheckpoint(ctx, errors.New("broken"))
+		started <- struct{}{}
+		<-release
+		return nil
+	}), "test-named-worker-health")
+
+	done := make(chan error, 2)
+	for range 2 {
+		go func() { done <- worker.Run(context.Background()) }()
+		<-started
+	}
+
+	assert.True(t, healthReportContains("/test-named-worker-health"))
+
+	release <- struct{}{}
+	assert.NoError(t, <-done)
+	assert.True(t, healthReportContains("/test-named-worker-health"),
+		"the monitor must stay while another worker of the same name runs")
+
+	release <- struct{}{}
+	assert.NoError(t, <-done)
+	assert.False(t, healthReportContains("/test-named-worker-health"))
+}
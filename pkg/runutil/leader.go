@@ -0,0 +1,190 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+const promLeaderSubsystem = "leader_election"
+
+var instLeaderIsLeader = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: PromNamespace,
+	Subsystem: promLeaderSubsystem,
+	Name:      "is_leader",
+}, []string{"worker_name"})
+
+// errLeadershipLost is used as context cause, when the lease of the leader
+// could not be renewed.
+var errLeadershipLost = errors.New("leadership lost")
+
+// LeaderElection runs a [Worker] on only a single replica at a time. See
+// [LeaderElected].
+type LeaderElection struct {
+	locker Locker
+	worker Worker
+	lease  time.Duration
+
+	leader atomic.Bool
+}
+
+type LeaderOption func(*LeaderElection)
+
+// WithLeaseDuration sets the TTL of the leadership lease. The lease gets
+// renewed every third of the duration. A shorter lease means a faster failover
+// but more load on the lock backend. Defaults to 15s.
+func WithLeaseDuration(d time.Duration) LeaderOption {
+	return func(l *LeaderElection) {
+		l.lease = d
+	}
+}
+
+// LeaderElected creates a worker that competes for the lock of the given
+// [Locker] and starts the wrapped worker only while it holds the leadership.
+// This is useful for singleton workers like queue consumers, that must only
+// run on a single pod.
+//
+// Behaviour:
+//   - The lease gets renewed periodically while the worker runs.
+//   - The context of the wrapped worker gets cancelled immediately, if the
+//     lease is taken over by someone else or if it could not be renewed
+//     before it expired. Afterwards it competes for the leadership again.
+//   - The lock gets released as soon as the wrapped worker exits.
+//   - If the wrapped worker exits on its own, its result is returned.
+//   - If the Locker implements [FencingLocker], the fencing token is available
+//     to the wrapped worker with [FencingToken].
+//
+// The current state is available with [LeaderElection.IsLeader] and as
+// Prometheus gauge rebuy_go_sdk_leader_election_is_leader.
+func LeaderElected(locker Locker, worker Worker, opts ...LeaderOption) *LeaderElection {
+	l := &LeaderElection{
+		locker: locker,
+		worker: worker,
+		lease:  15 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(l)
+	}
+
+	return l
+}
+
+// IsLeader returns true, if this instance currently holds the leadership.
+func (l *LeaderElection) IsLeader() bool {
+	return l.leader.Load()
+}
+
+func (l *LeaderElection) Run(ctx context.Context) error {
+	gauge := instLeaderIsLeader.WithLabelValues(logutil.GetSubsystem(ctx))
+	gauge.Set(0)
+
+	for ctx.Err() == nil {
+		ok, err := l.locker.TryLock(ctx, l.lease)
+		if err != nil {
+			logutil.Get(ctx).Warn("failed to acquire leadership", "error", err)
+		}
+
+		if !ok {
+			Wait(ctx, l.lease/3)
+			continue
+		}
+
+		logutil.Get(ctx).Info("acquired leadership")
+		l.leader.Store(true)
+		gauge.Set(1)
+
+		lost, err := l.lead(ctx)
+
+		l.leader.Store(false)
+		gauge.Set(0)
+
+		if lost == nil {
+			return err
+		}
+
+		logutil.Get(ctx).Warn("lost leadership", "error", lost)
+	}
+
+	return nil
+}
+
+// lead runs the worker while renewing the lease. The first return value is
+// not nil, if the worker was stopped because of a lost leadership. The second
+// one is the result of the worker.
+func (l *LeaderElection) lead(ctx context.Context) (error, error) {
+	workerCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if fl, ok := l.locker.(FencingLocker); ok {
+		workerCtx = contextWithFencingToken(workerCtx, fl.FencingToken())
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.renew(workerCtx, cancel)
+	}()
+
+	err := l.worker.Run(workerCtx)
+
+	var lost error
+	if cause := context.Cause(workerCtx); errors.Is(cause, errLeadershipLost) {
+		lost = cause
+	}
+
+	cancel(nil)
+	wg.Wait()
+
+	// Use a timeout context, because the parent context might already be
+	// cancelled, but the lock should still be released.
+	unlockCtx, unlockCancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+	defer unlockCancel()
+
+	unlockErr := l.locker.Unlock(unlockCtx)
+	if unlockErr != nil {
+		logutil.Get(ctx).Warn("failed to release leadership", "error", unlockErr)
+	}
+
+	return lost, err
+}
+
+func (l *LeaderElection) renew(ctx context.Context, cancel context.CancelCauseFunc) {
+	clock := ClockFromContext(ctx)
+
+	ticker := clock.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+
+	renewed := clock.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		ok, err := l.locker.Refresh(ctx, l.lease)
+		switch {
+		case err != nil && clock.Now().Sub(renewed) >= l.lease:
+			// The lease definitely expired, since the last successful renewal
+			// is longer ago than the lease duration.
+			cancel(errors.Join(errLeadershipLost, err))
+			return
+		case err != nil:
+			logutil.Get(ctx).Warn("failed to renew leadership", "error", err)
+		case !ok:
+			cancel(errLeadershipLost)
+			return
+		default:
+			renewed = clock.Now()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package runutil
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return mr, client
+}
+
+func TestRedisLocker(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	a := NewRedisLocker(client, "test-lock")
+	b := NewRedisLocker(client, "test-lock")
+
+	ok, err := a.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), a.(FencingLocker).FencingToken())
+
+	ok, err = b.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = b.Refresh(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "refresh must fail for non-owner")
+
+	require.NoError(t, b.Unlock(ctx))
+	ttl, err := a.TTL(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0), "unlock must not release lock of other owner")
+
+	ok, err = a.Refresh(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, a.Unlock(ctx))
+	ttl, err = a.TTL(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	ok, err = b.TryLock(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), b.(FencingLocker).FencingToken())
+}
+
+func TestLeaderElectedSingleLeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, client := newTestRedis(t)
+
+	var running atomic.Int32
+	worker := WorkerFunc(func(ctx context.Context) error {
+		running.Add(1)
+		defer running.Add(-1)
+		<-ctx.Done()
+		return nil
+	})
+
+	a := LeaderElected(NewRedisLocker(client, "leader"), worker, WithLeaseDuration(300*time.Millisecond))
+	b := LeaderElected(NewRedisLocker(client, "leader"), worker, WithLeaseDuration(300*time.Millisecond))
+
+	done := make(chan error, 2)
+	go func() { done <- a.Run(ctx) }()
+	go func() { done <- b.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return a.IsLeader() || b.IsLeader()
+	}, time.Second, 10*time.Millisecond)
+
+	// Wait for a few renewals to make sure the leadership is stable.
+	time.Sleep(500 * time.Millisecond)
+	assert.NotEqual(t, a.IsLeader(), b.IsLeader())
+	assert.Equal(t, int32(1), running.Load())
+
+	cancel()
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	assert.False(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+}
+
+func TestLeaderElectedCancelsOnLeaseLoss(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mr, client := newTestRedis(t)
+
+	var starts atomic.Int32
+	stopped := make(chan struct{}, 10)
+	worker := WorkerFunc(func(ctx context.Context) error {
+		starts.Add(1)
+		<-ctx.Done()
+		stopped <- struct{}{}
+		return nil
+	})
+
+	l := LeaderElected(NewRedisLocker(client, "leader"), worker, WithLeaseDuration(300*time.Millisecond))
+
+	done := make(chan error, 1)
+	go func() { done <- l.Run(ctx) }()
+
+	require.Eventually(t, l.IsLeader, time.Second, 10*time.Millisecond)
+
+	// Simulate another replica stealing the lock.
+	mr.Set("leader", "someone-else")
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("worker was not stopped after lease loss")
+	}
+
+	require.Eventually(t, func() bool {
+		return !l.IsLeader()
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), starts.Load())
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,178 @@
+package runutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker is a distributed lock with a lease that expires after a TTL. Each
+// Locker instance is a single participant that competes for a named lock, so
+// two instances for the same name never hold the lock at the same time, even
+// within the same process.
+type Locker interface {
+	// TryLock acquires the lock, if it is not held by anyone. It returns true,
+	// if the lock was acquired.
+	TryLock(ctx context.Context, ttl time.Duration) (bool, error)
+
+	// Refresh extends the lease of the held lock by the given TTL. It returns
+	// false, if the lock is not held by this Locker anymore (eg because it
+	// expired and got acquired by someone else).
+	Refresh(ctx context.Context, ttl time.Duration) (bool, error)
+
+	// Unlock releases the lock, if it is held by this Locker.
+	Unlock(ctx context.Context) error
+
+	// TTL returns the remaining lease time of the lock, regardless of who
+	// holds it. It returns zero, if nobody holds the lock.
+	TTL(ctx context.Context) (time.Duration, error)
+}
+
+// FencingLocker is optionally implemented by a [Locker] that hands out fencing
+// tokens. A fencing token is a number that increases monotonically with every
+// successful [Locker.TryLock] for the same lock name. External systems can use
+// it to reject writes from a former lock holder, that did not notice yet that
+// it lost the lock.
+type FencingLocker interface {
+	Locker
+
+	// FencingToken returns the token of the latest successful TryLock.
+	FencingToken() int64
+}
+
+type fencingTokenContextKey struct{}
+
+func contextWithFencingToken(ctx context.Context, token int64) context.Context {
+	return context.WithValue(ctx, fencingTokenContextKey{}, token)
+}
+
+// FencingToken returns the fencing token of the lock that is held while the
+// current job or worker runs (see [FencingLocker]). The second return value is
+// false, if there is no fencing token in the context.
+func FencingToken(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(fencingTokenContextKey{}).(int64)
+	return token, ok
+}
+
+// NewLockToken creates a unique value to identify the holder of a lock. It
+// contains the hostname to make debugging easier. It is meant for
+// implementations of [Locker].
+func NewLockToken() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("%s-%s", hostname, hex.EncodeToString(b))
+}
+
+var (
+	// redisLockAcquireScript sets the lock and increments the fencing token in
+	// a single step. It returns the new fencing token or 0, if the lock is
+	// already held.
+	redisLockAcquireScript = redis.NewScript(`
+		if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+			return redis.call("INCR", KEYS[2])
+		end
+		return 0`)
+
+	redisLockRefreshScript = redis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		end
+		return 0`)
+
+	redisLockUnlockScript = redis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+		return 0`)
+)
+
+type redisLocker struct {
+	client redis.UniversalClient
+	name   string
+	token  string
+	fence  atomic.Int64
+}
+
+// NewRedisLocker creates a [Locker] that uses the given Redis key as lock. The
+// ownership is tracked with a unique token as value, which is verified
+// atomically on refresh and unlock.
+//
+// It implements [FencingLocker]. The fencing token is stored in a separate key
+// with the suffix ":fencing" and has no expiry. The key uses a hash tag to be
+// stored on the same node in a Redis Cluster.
+//
+// Like [NewDistributedRepeat], this does not use the Redlock algorithm and
+// therefore does not have any correctness guarantees when Redis fails over.
+func NewRedisLocker(client redis.UniversalClient, name string) Locker {
+	return &redisLocker{
+		client: client,
+		name:   name,
+		token:  NewLockToken(),
+	}
+}
+
+func (l *redisLocker) TryLock(ctx context.Context, ttl time.Duration) (bool, error) {
+	keys := []string{l.name, l.fencingKey()}
+	fence, err := redisLockAcquireScript.Run(ctx, l.client,
+		keys, l.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("acquire lock %#v: %w", l.name, err)
+	}
+
+	if fence == 0 {
+		return false, nil
+	}
+
+	l.fence.Store(fence)
+	return true, nil
+}
+
+func (l *redisLocker) FencingToken() int64 {
+	return l.fence.Load()
+}
+
+func (l *redisLocker) fencingKey() string {
+	return "{" + l.name + "}:fencing"
+}
+
+func (l *redisLocker) Refresh(ctx context.Context, ttl time.Duration) (bool, error) {
+	n, err := redisLockRefreshScript.Run(ctx, l.client,
+		[]string{l.name}, l.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("refresh lock %#v: %w", l.name, err)
+	}
+
+	return n == 1, nil
+}
+
+func (l *redisLocker) Unlock(ctx context.Context) error {
+	err := redisLockUnlockScript.Run(ctx, l.client, []string{l.name}, l.token).Err()
+	if err != nil {
+		return fmt.Errorf("unlock %#v: %w", l.name, err)
+	}
+
+	return nil
+}
+
+func (l *redisLocker) TTL(ctx context.Context) (time.Duration, error) {
+	ttl, err := l.client.PTTL(ctx, l.name).Result()
+	if err != nil {
+		return 0, fmt.Errorf("get ttl %#v for lock: %w", l.name, err)
+	}
+
+	// Redis returns negative values, if the key does not exist or has no
+	// expiry.
+	return max(ttl, 0), nil
+}
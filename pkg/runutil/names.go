@@ -9,9 +9,12 @@ import (
 )
 
 // NamedWorker assigns a new logutil subsystem on startup. See logutil.Start.
+// The health monitor of the subsystem gets removed, when the worker returned
+// (see [HealthReport]).
 func NamedWorker(worker Worker, name string) Worker {
 	return WorkerFunc(func(ctx context.Context) error {
 		ctx = logutil.Start(ctx, name)
+		defer healthRegistry.startWorker(logutil.GetSubsystem(ctx))()
 		return worker.Run(ctx)
 	})
 }
@@ -19,8 +22,12 @@ func NamedWorker(worker Worker, name string) Worker {
 // NamedWorkerFromType assigns a new logutil subsystem on startup based on the
 // provided type name. See logutil.Start.
 func NamedWorkerFromType(worker Worker, t any) Worker {
+	return NamedWorker(worker, typeName(t))
+}
+
+func typeName(t any) string {
 	name := fmt.Sprintf("%T", t)
 	name = strings.Trim(name, "*")
 	name = strings.Replace(name, ".", "/", 1)
-	return NamedWorker(worker, name)
+	return name
 }
@@ -0,0 +1,244 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+const promPoolSubsystem = "pool"
+
+var (
+	instPoolItemsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: PromNamespace,
+		Subsystem: promPoolSubsystem,
+		Name:      "items_total",
+	}, []string{"pool", "result"})
+
+	instPoolItemsPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: PromNamespace,
+		Subsystem: promPoolSubsystem,
+		Name:      "items_pending",
+	}, []string{"pool"})
+
+	instPoolItemsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: PromNamespace,
+		Subsystem: promPoolSubsystem,
+		Name:      "items_in_flight",
+	}, []string{"pool"})
+)
+
+type poolConfig struct {
+	concurrency int
+	name        string
+	limiter     RateLimiter
+	failFast    bool
+}
+
+type PoolOption func(*poolConfig)
+
+// WithPoolName sets the name that is used as label for the Prometheus metrics.
+// Defaults to the logutil subsystem of the context.
+func WithPoolName(name string) PoolOption {
+	return func(c *poolConfig) {
+		c.name = name
+	}
+}
+
+// WithPoolInterval limits the rate of the pool, so there is at least the given
+// interval between the start of two items.
+func WithPoolInterval(d time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		if d > 0 {
+			c.limiter = NewTokenBucket(float64(time.Second)/float64(d), 1)
+		}
+	}
+}
+
+// WithPoolRateLimiter waits for the [RateLimiter] before starting each item.
+// This way the rate can be shared with other pools or replicas.
+func WithPoolRateLimiter(limiter RateLimiter) PoolOption {
+	return func(c *poolConfig) {
+		c.limiter = limiter
+	}
+}
+
+// WithFailFast stops processing further items after the first error and
+// cancels the context of the items that are still running. Only the first
+// error is returned. By default all items get processed and all errors are
+// returned.
+func WithFailFast() PoolOption {
+	return func(c *poolConfig) {
+		c.failFast = true
+	}
+}
+
+// Pool processes items of type T with a bounded number of goroutines. Unlike
+// [RunAllJobs], it is suitable for a large number of items. A single Pool can
+// be used multiple times, also concurrently.
+//
+// It reports the progress as Prometheus metrics with the prefix
+// rebuy_go_sdk_pool. See [WithPoolName].
+type Pool[T any] struct {
+	config poolConfig
+}
+
+// NewPool creates a [Pool] that processes at most concurrency items at the
+// same time. A concurrency below 1 is treated as 1.
+func NewPool[T any](concurrency int, opts ...PoolOption) *Pool[T] {
+	return &Pool[T]{config: newPoolConfig(concurrency, opts)}
+}
+
+func newPoolConfig(concurrency int, opts []PoolOption) poolConfig {
+	c := poolConfig{
+		concurrency: max(concurrency, 1),
+	}
+
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return c
+}
+
+// ForEach calls fn for every item and waits until all calls returned. It
+// returns the joined errors of all calls or only the first one with
+// [WithFailFast]. If the context gets cancelled or the rate limiter fails, the
+// remaining items are skipped and the context or limiter error is returned as
+// well.
+func (p *Pool[T]) ForEach(ctx context.Context, items []T, fn func(context.Context, T) error) error {
+	return p.config.run(ctx, len(items), func(ctx context.Context, i int) error {
+		return fn(ctx, items[i])
+	})
+}
+
+// ForEach processes all items with a temporary [Pool]. See [Pool.ForEach].
+func ForEach[T any](ctx context.Context, items []T, concurrency int, fn func(context.Context, T) error, opts ...PoolOption) error {
+	return NewPool[T](concurrency, opts...).ForEach(ctx, items, fn)
+}
+
+// Map is like [ForEach], but collects the results of fn. The results have the
+// same order as the items. The result of a failed or skipped item is the zero
+// value.
+func Map[T, R any](ctx context.Context, items []T, concurrency int, fn func(context.Context, T) (R, error), opts ...PoolOption) ([]R, error) {
+	results := make([]R, len(items))
+	config := newPoolConfig(concurrency, opts)
+
+	err := config.run(ctx, len(items), func(ctx context.Context, i int) error {
+		result, err := fn(ctx, items[i])
+		if err != nil {
+			return err
+		}
+
+		// Each index is only written by a single goroutine, therefore no
+		// lock is needed.
+		results[i] = result
+		return nil
+	})
+
+	return results, err
+}
+
+func (c poolConfig) run(ctx context.Context, n int, fn func(context.Context, int) error) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	name := c.name
+	if name == "" {
+		name = logutil.GetSubsystem(parent)
+	}
+
+	var (
+		pending  = instPoolItemsPending.WithLabelValues(name)
+		inFlight = instPoolItemsInFlight.WithLabelValues(name)
+		success  = instPoolItemsTotal.WithLabelValues(name, "success")
+		failure  = instPoolItemsTotal.WithLabelValues(name, "failure")
+		skipped  = instPoolItemsTotal.WithLabelValues(name, "skipped")
+	)
+
+	pending.Add(float64(n))
+
+	var (
+		wg        sync.WaitGroup
+		errs      collector[error]
+		firstErr  error
+		firstOnce sync.Once
+		sem       = make(chan struct{}, c.concurrency)
+		started   int
+	)
+
+dispatch:
+	for i := range n {
+		if c.limiter != nil {
+			err := c.limiter.Wait(ctx)
+			if err != nil {
+				// Context errors are handled below, but other errors
+				// (eg Redis is not reachable) would be lost otherwise.
+				if ctx.Err() == nil {
+					errs.Append(fmt.Errorf("wait for rate limiter: %w", err))
+				}
+				break dispatch
+			}
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		started++
+		pending.Dec()
+		inFlight.Inc()
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer inFlight.Dec()
+
+			err := fn(ctx, i)
+			if err == nil {
+				success.Inc()
+				return
+			}
+
+			failure.Inc()
+			errs.Append(err)
+
+			if c.failFast {
+				firstOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	pending.Sub(float64(n - started))
+	skipped.Add(float64(n - started))
+
+	if started < n && parent.Err() != nil && firstErr == nil {
+		errs.Append(parent.Err())
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return errors.Join(errs.Result()...)
+}
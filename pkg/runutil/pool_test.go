@@ -0,0 +1,164 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEachBoundedConcurrency(t *testing.T) {
+	items := make([]int, 100)
+
+	var current, peak atomic.Int32
+	err := ForEach(context.Background(), items, 5, func(ctx context.Context, _ int) error {
+		n := current.Add(1)
+		defer current.Add(-1)
+
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.LessOrEqual(t, peak.Load(), int32(5))
+	assert.Greater(t, peak.Load(), int32(1))
+}
+
+func TestForEachCollectsAllErrors(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6}
+
+	var calls atomic.Int32
+	err := ForEach(context.Background(), items, 2, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		if i%2 == 0 {
+			return fmt.Errorf("item %d failed", i)
+		}
+		return nil
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, int32(6), calls.Load())
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+}
+
+func TestForEachFailFast(t *testing.T) {
+	items := make([]int, 100)
+	errFirst := errors.New("first")
+
+	var calls atomic.Int32
+	err := ForEach(context.Background(), items, 1, func(ctx context.Context, _ int) error {
+		if calls.Add(1) == 3 {
+			return errFirst
+		}
+		return nil
+	}, WithFailFast())
+
+	require.Equal(t, errFirst, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestForEachContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	items := make([]int, 100)
+	var calls atomic.Int32
+	err := ForEach(ctx, items, 1, func(ctx context.Context, _ int) error {
+		if calls.Add(1) == 5 {
+			cancel()
+		}
+		return nil
+	})
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(5), calls.Load())
+}
+
+func TestForEachInterval(t *testing.T) {
+	items := make([]int, 4)
+
+	start := time.Now()
+	err := ForEach(context.Background(), items, 4, func(ctx context.Context, _ int) error {
+		return nil
+	}, WithPoolInterval(20*time.Millisecond))
+
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+func TestMapOrderedResults(t *testing.T) {
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+
+	results, err := Map(context.Background(), items, 8, func(ctx context.Context, i int) (string, error) {
+		time.Sleep(time.Duration(50-i) * 100 * time.Microsecond)
+		if i == 7 {
+			return "", errors.New("seven")
+		}
+		return fmt.Sprint(i * 2), nil
+	})
+
+	require.EqualError(t, err, "seven")
+	require.Len(t, results, 50)
+	for i, r := range results {
+		if i == 7 {
+			assert.Empty(t, r)
+			continue
+		}
+		assert.Equal(t, fmt.Sprint(i*2), r)
+	}
+}
+
+func TestPoolReusable(t *testing.T) {
+	pool := NewPool[string](2, WithPoolName("test"))
+
+	var sum atomic.Int32
+	for range 3 {
+		err := pool.ForEach(context.Background(), []string{"a", "bb", "ccc"}, func(ctx context.Context, s string) error {
+			sum.Add(int32(len(s)))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(18), sum.Load())
+}
+
+type failingRateLimiter struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (l *failingRateLimiter) Wait(ctx context.Context) error {
+	if l.calls.Add(1) > 2 {
+		return l.err
+	}
+	return nil
+}
+
+func TestForEachRateLimiterError(t *testing.T) {
+	errLimiter := errors.New("redis is down")
+	limiter := &failingRateLimiter{err: errLimiter}
+
+	var calls atomic.Int32
+	err := ForEach(context.Background(), make([]int, 10), 1, func(ctx context.Context, _ int) error {
+		calls.Add(1)
+		return nil
+	}, WithPoolRateLimiter(limiter))
+
+	require.ErrorIs(t, err, errLimiter)
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,270 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/redis/go-redis/v9"
+)
+
+const promRateLimiterSubsystem = "rate_limiter"
+
+var (
+	instRateLimiterRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: PromNamespace,
+		Subsystem: promRateLimiterSubsystem,
+		Name:      "requests_total",
+	}, []string{"limiter", "result"})
+
+	instRateLimiterWaitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: PromNamespace,
+		Subsystem: promRateLimiterSubsystem,
+		Name:      "wait_seconds",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60},
+	}, []string{"limiter"})
+)
+
+// RateLimiter limits how often an action can happen.
+type RateLimiter interface {
+	// Wait blocks until the action is allowed or the context gets cancelled.
+	// In the latter case it returns the error of the context.
+	Wait(ctx context.Context) error
+}
+
+type rateLimiterConfig struct {
+	name  string
+	clock Clock
+}
+
+type RateLimiterOption func(*rateLimiterConfig)
+
+// WithRateLimiterName sets the name that is used as label for the Prometheus
+// metrics. Defaults to the logutil subsystem of the context.
+func WithRateLimiterName(name string) RateLimiterOption {
+	return func(c *rateLimiterConfig) {
+		c.name = name
+	}
+}
+
+// WithRateLimiterClock sets the [Clock] of the rate limiter. The rate limiter
+// does not use the clock of the context (see [WithClock]), because its state
+// is shared between all callers. Defaults to the real clock.
+func WithRateLimiterClock(clock Clock) RateLimiterOption {
+	return func(c *rateLimiterConfig) {
+		c.clock = clock
+	}
+}
+
+func newRateLimiterConfig(opts []RateLimiterOption) rateLimiterConfig {
+	c := rateLimiterConfig{clock: realClock{}}
+	for _, o := range opts {
+		o(&c)
+	}
+	return c
+}
+
+// mustPositiveRate panics for rates that would lead to a division by zero or
+// infinite waits.
+func mustPositiveRate(rate float64) {
+	if !(rate > 0) {
+		panic(fmt.Sprintf("runutil: rate limiter rate must be positive, got %v", rate))
+	}
+}
+
+// observe records the result of a Wait call in the Prometheus metrics.
+func (c rateLimiterConfig) observe(ctx context.Context, waited time.Duration, err error) {
+	name := c.name
+	if name == "" {
+		name = logutil.GetSubsystem(ctx)
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		instRateLimiterRequestsTotal.WithLabelValues(name, "cancelled").Inc()
+	case err != nil:
+		instRateLimiterRequestsTotal.WithLabelValues(name, "error").Inc()
+	case waited > 0:
+		instRateLimiterRequestsTotal.WithLabelValues(name, "throttled").Inc()
+	default:
+		instRateLimiterRequestsTotal.WithLabelValues(name, "allowed").Inc()
+	}
+
+	instRateLimiterWaitSeconds.WithLabelValues(name).Observe(waited.Seconds())
+}
+
+// TokenBucket is a [RateLimiter] that is local to the process. The bucket
+// holds up to burst tokens and gets refilled with rate tokens per second.
+// Each action takes one token. See [NewTokenBucket].
+type TokenBucket struct {
+	config rateLimiterConfig
+	rate   float64
+	burst  float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a [TokenBucket] that allows rate actions per second
+// on average and up to burst actions at once. The bucket starts full. A burst
+// below 1 is treated as 1. It panics, if the rate is not positive.
+func NewTokenBucket(rate float64, burst int, opts ...RateLimiterOption) *TokenBucket {
+	mustPositiveRate(rate)
+
+	config := newRateLimiterConfig(opts)
+
+	return &TokenBucket{
+		config: config,
+		rate:   rate,
+		burst:  float64(max(burst, 1)),
+		tokens: float64(max(burst, 1)),
+		last:   config.clock.Now(),
+	}
+}
+
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	wait := b.reserve()
+
+	var err error
+	if wait > 0 {
+		select {
+		case <-b.config.clock.After(wait):
+		case <-ctx.Done():
+			b.cancel()
+			err = ctx.Err()
+		}
+	}
+
+	b.config.observe(ctx, wait, err)
+	return err
+}
+
+// reserve takes a token and returns how long the caller has to wait until the
+// token is actually available. The number of tokens gets negative, if there
+// are waiting callers.
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.config.clock.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token.
+func (b *TokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// redisRateLimitScript implements a token bucket in Redis. It uses the time of
+// the Redis server to be independent of clock skew between replicas. It takes
+// a token and returns 0, if one is available. Otherwise it returns the number
+// of milliseconds until the next token is available.
+var redisRateLimitScript = redis.NewScript(`
+	local rate = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+
+	local time = redis.call("TIME")
+	local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+	local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+	local tokens = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+
+	tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+	if tokens < 1 then
+		return math.ceil((1 - tokens) * 1000 / rate)
+	end
+
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens - 1), "ts", tostring(now))
+	redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+	return 0`)
+
+type redisRateLimiter struct {
+	config rateLimiterConfig
+	client redis.UniversalClient
+	key    string
+	rate   float64
+	burst  int
+}
+
+// NewRedisRateLimiter creates a token bucket [RateLimiter] that is stored in
+// the given Redis key and therefore shared between all replicas. It has the
+// same semantics as [NewTokenBucket].
+//
+// Unlike the local [TokenBucket], waiting callers do not reserve tokens, but
+// retry after the time the next token is expected. Therefore the order of
+// waiting callers is not guaranteed.
+func NewRedisRateLimiter(client redis.UniversalClient, key string, rate float64, burst int, opts ...RateLimiterOption) RateLimiter {
+	mustPositiveRate(rate)
+
+	return &redisRateLimiter{
+		config: newRateLimiterConfig(opts),
+		client: client,
+		key:    key,
+		rate:   rate,
+		burst:  max(burst, 1),
+	}
+}
+
+func (l *redisRateLimiter) Wait(ctx context.Context) error {
+	var waited time.Duration
+
+	for {
+		ms, err := redisRateLimitScript.Run(ctx, l.client,
+			[]string{l.key}, l.rate, l.burst).Int64()
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			} else {
+				err = fmt.Errorf("take token from rate limiter %#v: %w", l.key, err)
+			}
+
+			l.config.observe(ctx, waited, err)
+			return err
+		}
+
+		if ms == 0 {
+			l.config.observe(ctx, waited, nil)
+			return nil
+		}
+
+		wait := time.Duration(ms) * time.Millisecond
+		waited += wait
+
+		select {
+		case <-l.config.clock.After(wait):
+		case <-ctx.Done():
+			l.config.observe(ctx, waited, ctx.Err())
+			return ctx.Err()
+		}
+	}
+}
+
+// RateLimitJob waits for the [RateLimiter] before every execution of the job.
+func RateLimitJob(limiter RateLimiter, job Job) Job {
+	return JobFunc(func(ctx context.Context) error {
+		err := limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		return job.RunOnce(ctx)
+	})
+}
@@ -0,0 +1,117 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucketBurstAndRate(t *testing.T) {
+	ctx := context.Background()
+	bucket := NewTokenBucket(50, 3)
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, bucket.Wait(ctx))
+	}
+	assert.Less(t, time.Since(start), 10*time.Millisecond, "burst should not wait")
+
+	for range 3 {
+		require.NoError(t, bucket.Wait(ctx))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond, "3 tokens at 50/s take 60ms")
+}
+
+func TestTokenBucketCancelReturnsToken(t *testing.T) {
+	bucket := NewTokenBucket(1, 1)
+	require.NoError(t, bucket.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := bucket.Wait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	assert.Greater(t, bucket.tokens, -0.5, "cancelled reservation must be returned")
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	a := NewRedisRateLimiter(client, "test-limit", 50, 2)
+	b := NewRedisRateLimiter(client, "test-limit", 50, 2)
+
+	start := time.Now()
+	require.NoError(t, a.Wait(ctx))
+	require.NoError(t, b.Wait(ctx))
+	assert.Less(t, time.Since(start), 10*time.Millisecond, "burst should not wait")
+
+	require.NoError(t, a.Wait(ctx))
+	require.NoError(t, b.Wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond,
+		"both limiters share the same bucket")
+}
+
+func TestRedisRateLimiterCancel(t *testing.T) {
+	_, client := newTestRedis(t)
+	limiter := NewRedisRateLimiter(client, "test-cancel", 0.1, 1)
+
+	require.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, limiter.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestRateLimitJob(t *testing.T) {
+	var calls int
+	job := RateLimitJob(NewTokenBucket(100, 1), JobFunc(func(ctx context.Context) error {
+		calls++
+		return nil
+	}))
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, job.RunOnce(context.Background()))
+	}
+
+	assert.Equal(t, 3, calls)
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+}
+
+func TestRateLimiterRejectsInvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		assert.Panics(t, func() { NewTokenBucket(rate, 1) }, "rate %v", rate)
+		assert.Panics(t, func() { NewRedisRateLimiter(nil, "key", rate, 1) }, "rate %v", rate)
+	}
+}
+
+func TestRateLimiterObserveResults(t *testing.T) {
+	config := rateLimiterConfig{name: "test-observe"}
+
+	cases := []struct {
+		err    error
+		result string
+	}{
+		{context.Canceled, "cancelled"},
+		{context.DeadlineExceeded, "cancelled"},
+		{errors.New("redis is down"), "error"},
+	}
+
+	for _, tc := range cases {
+		counter := instRateLimiterRequestsTotal.WithLabelValues("test-observe", tc.result)
+		before := metricValue(t, counter)
+
+		config.observe(context.Background(), 0, tc.err)
+		assert.Equal(t, before+1, metricValue(t, counter), "error %v", tc.err)
+	}
+}
@@ -0,0 +1,81 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+// errJobPanicked is attached to the tracing span of a job that panicked.
+var errJobPanicked = errors.New("job panicked")
+
+// PanicError is returned by workers and jobs that are wrapped with [Recover]
+// or [RecoverJob], when they panicked.
+type PanicError struct {
+	// Value is the value that was passed to panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value, if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover converts panics of the worker into a [*PanicError]. This way a
+// panicking worker does not crash the whole process, but can be restarted by
+// [Retry] or a [Supervisor].
+func Recover(worker Worker) Worker {
+	return WorkerFunc(func(ctx context.Context) (err error) {
+		defer recoverPanic(ctx, &err)
+		return worker.Run(ctx)
+	})
+}
+
+// RecoverJob converts panics of the job into a [*PanicError]. See [Recover].
+func RecoverJob(job Job) Job {
+	return JobFunc(func(ctx context.Context) (err error) {
+		defer recoverPanic(ctx, &err)
+		return job.RunOnce(ctx)
+	})
+}
+
+// recoverPanic must be called with defer. It recovers a panic, reports it to
+// the logs, the health monitor and the current tracing span and replaces the
+// returned error with a [*PanicError].
+func recoverPanic(ctx context.Context, errp *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	pe := &PanicError{
+		Value: r,
+		Stack: debug.Stack(),
+	}
+
+	logutil.Get(ctx).Error("recovered from panic",
+		"error", pe, "stack", string(pe.Stack))
+
+	HealthCheckpoint(ctx, pe)
+
+	span, ok := tracer.SpanFromContext(ctx)
+	if ok {
+		span.SetTag(ext.Error, pe)
+		span.SetTag(ext.ErrorStack, string(pe.Stack))
+	}
+
+	*errp = pe
+}
@@ -0,0 +1,82 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverJob(t *testing.T) {
+	job := RecoverJob(JobFunc(func(ctx context.Context) error {
+		panic("boom")
+	}))
+
+	err := job.RunOnce(context.Background())
+
+	var pe *PanicError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "boom", pe.Value)
+	assert.Equal(t, "panic: boom", pe.Error())
+	assert.Contains(t, string(pe.Stack), "TestRecoverJob")
+}
+
+func TestRecoverUnwrapsErrorValue(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	worker := Recover(WorkerFunc(func(ctx context.Context) error {
+		panic(errBoom)
+	}))
+
+	err := worker.Run(context.Background())
+	require.ErrorIs(t, err, errBoom)
+}
+
+func TestRecoverPassesThroughErrors(t *testing.T) {
+	errFail := errors.New("fail")
+
+	worker := Recover(WorkerFunc(func(ctx context.Context) error {
+		return errFail
+	}))
+
+	require.Equal(t, errFail, worker.Run(context.Background()))
+}
+
+func TestRecoverRepeat(t *testing.T) {
+	worker := Recover(Repeat(time.Hour, JobFunc(func(ctx context.Context) error {
+		panic("boom")
+	}), WithStartImmediately()))
+
+	err := worker.Run(context.Background())
+
+	var pe *PanicError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "boom", pe.Value)
+}
+
+func TestDeclarativeWorkerRecoverPanics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+
+	err := DeclarativeWorker{
+		Name: "panicking",
+		Worker: WorkerFunc(func(ctx context.Context) error {
+			if calls.Add(1) < 3 {
+				panic("boom")
+			}
+			cancel()
+			return nil
+		}),
+		Retry:         StaticBackoff{Sleep: time.Millisecond},
+		RecoverPanics: true,
+	}.Run(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
@@ -1,24 +1,116 @@
 package runutil
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 )
 
+const promJobSubsystem = "job"
+
+var (
+	instJobDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "duration_seconds",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"worker_name", "result"})
+
+	instJobInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "in_flight",
+	}, []string{"worker_name"})
+
+	instJobLastSuccessTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "last_success_timestamp_seconds",
+	}, []string{"worker_name"})
+
+	instJobLastFailureTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "last_failure_timestamp_seconds",
+	}, []string{"worker_name"})
+
+	instJobOverrunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "overruns_total",
+	}, []string{"worker_name", "action"})
+
+	instJobTimeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "timeouts_total",
+	}, []string{"worker_name"})
+
+	instJobIgnoredErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: PromNamespace,
+		Subsystem: promJobSubsystem,
+		Name:      "ignored_errors_total",
+	}, []string{"worker_name"})
+)
+
+// OverrunPolicy defines what a [Repeat] worker does, when the job is still
+// running at the time of the next scheduled run.
+type OverrunPolicy int
+
+const (
+	// OverrunSkip drops the scheduled run.
+	OverrunSkip OverrunPolicy = iota
+
+	// OverrunQueue runs the job again right after the current run finished.
+	// At most one run gets queued, further overruns are skipped.
+	OverrunQueue
+
+	// OverrunConcurrent starts another run in parallel to the current one.
+	// There is no limit on the number of concurrent runs.
+	OverrunConcurrent
+)
+
+func (p OverrunPolicy) String() string {
+	switch p {
+	case OverrunSkip:
+		return "skip"
+	case OverrunQueue:
+		return "queue"
+	case OverrunConcurrent:
+		return "concurrent"
+	default:
+		return fmt.Sprintf("OverrunPolicy(%d)", int(p))
+	}
+}
+
 type jobWorker struct {
 	wait             time.Duration
 	job              Job
 	startImmediately bool
+	runTimeout       time.Duration
+	overrun          OverrunPolicy
+	continueOnError  bool
 }
 
 // Repeat reruns a job indefinitely until the context gets cancelled. The job
 // will run at most once in the given time interval. This means the wait
 // duration is not the sleep between executions, but the time between the start
 // of runs (based on [time.Ticker]).
+//
+// By default the worker stops when the job returns an error and skips
+// scheduled runs while the job is still running. See [WithContinueOnError]
+// and [WithOverrunPolicy].
+//
+// The job can be triggered, paused and resumed at runtime with its
+// [JobControl].
 func Repeat(wait time.Duration, job Job, opts ...RepeatOption) Worker {
 	w := &jobWorker{
 		wait: wait,
@@ -40,38 +132,202 @@ func WithStartImmediately() RepeatOption {
 	}
 }
 
+// WithRunTimeout cancels the context of a run after the given duration. The
+// job must honour the context, otherwise it cannot be interrupted. A run that
+// timed out counts as failed, see [WithContinueOnError].
+func WithRunTimeout(d time.Duration) RepeatOption {
+	return func(w *jobWorker) {
+		w.runTimeout = d
+	}
+}
+
+// WithOverrunPolicy defines what happens with scheduled runs while the job is
+// still running. Defaults to [OverrunSkip]. Manually triggered runs are always
+// queued, unless the policy is [OverrunConcurrent].
+func WithOverrunPolicy(policy OverrunPolicy) RepeatOption {
+	return func(w *jobWorker) {
+		w.overrun = policy
+	}
+}
+
+// WithContinueOnError logs errors of the job and keeps the schedule, instead
+// of stopping the worker.
+func WithContinueOnError() RepeatOption {
+	return func(w *jobWorker) {
+		w.continueOnError = true
+	}
+}
+
 func (w jobWorker) Run(ctx context.Context) error {
-	if w.startImmediately {
-		err := w.runOnce(ctx)
-		if err != nil {
-			return err
+	control, unregister := registerJobControl(ctx)
+	defer unregister()
+
+	// The runs get their own context, so they can be cancelled when another
+	// run fails while using OverrunConcurrent.
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		done    = make(chan error)
+		running int
+		queued  bool
+	)
+
+	// The runs happen in their own goroutines, therefore panics need to be
+	// recovered there. Otherwise they would crash the process, even if the
+	// worker is wrapped with Recover.
+	start := func() {
+		running++
+		go func() {
+			var err error
+			defer func() { done <- err }()
+			defer recoverPanic(runCtx, &err)
+			err = w.runOnce(runCtx, control)
+		}()
+	}
+
+	// wait collects the results of the remaining runs and returns the first
+	// error.
+	wait := func(err error) error {
+		for ; running > 0; running-- {
+			err = cmp.Or(err, <-done)
 		}
+		return err
+	}
+
+	if w.startImmediately && !control.skip(ctx) {
+		start()
 	}
 
-	ticker := time.NewTicker(w.wait)
+	ticker := ClockFromContext(ctx).NewTicker(w.wait)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			err := w.runOnce(ctx)
+			return wait(nil)
+		case err := <-done:
+			running--
 			if err != nil {
-				return err
+				cancel()
+				return wait(err)
+			}
+
+			if queued && running == 0 {
+				queued = false
+				start()
+			}
+			continue
+		case <-control.triggered():
+			logutil.Get(ctx).Info("running manually triggered job")
+			if running > 0 && w.overrun != OverrunConcurrent {
+				queued = true
+				continue
+			}
+		case <-ticker.C():
+			if control.skip(ctx) {
+				continue
+			}
+
+			if running > 0 && !w.handleOverrun(ctx, &queued) {
+				continue
 			}
 		}
+
+		start()
+	}
+}
+
+// handleOverrun logs and counts a scheduled run, that happens while the job is
+// still running. It returns true, if the run should start immediately.
+func (w jobWorker) handleOverrun(ctx context.Context, queued *bool) bool {
+	policy := w.overrun
+	if policy == OverrunQueue && *queued {
+		policy = OverrunSkip
+	}
+
+	instJobOverrunsTotal.WithLabelValues(logutil.GetSubsystem(ctx), policy.String()).Inc()
+
+	switch policy {
+	case OverrunQueue:
+		logutil.Get(ctx).Warn("job is still running, queueing next run")
+		*queued = true
+		return false
+	case OverrunConcurrent:
+		logutil.Get(ctx).Warn("job is still running, starting next run concurrently")
+		return true
+	default:
+		logutil.Get(ctx).Warn("job is still running, skipping scheduled run")
+		return false
 	}
 }
 
-func (w jobWorker) runOnce(ctx context.Context) error {
+// runOnce executes a single run with the configured timeout. It returns nil
+// for errors that should not stop the worker.
+func (w jobWorker) runOnce(ctx context.Context, control *JobControl) error {
+	runCtx := ctx
+	if w.runTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, w.runTimeout)
+		defer cancel()
+	}
+
+	err := control.run(runCtx, w.job)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	name := logutil.GetSubsystem(ctx)
+
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		instJobTimeoutsTotal.WithLabelValues(name).Inc()
+		logutil.Get(ctx).Warn("job run timed out", "timeout", w.runTimeout, "error", err)
+	}
+
+	if !w.continueOnError {
+		return err
+	}
+
+	instJobIgnoredErrorsTotal.WithLabelValues(name).Inc()
+	logutil.Get(ctx).Error("job failed, continuing with schedule", "error", err)
+	return nil
+}
+
+// runJob executes a single run of a scheduled job. It wraps the run into a
+// tracing span, records the result as health checkpoint and reports the
+// Prometheus metrics with the prefix rebuy_go_sdk_job. It is shared by all
+// scheduling workers (eg [Repeat], [Cron] and [DistributedRepeat]).
+func runJob(ctx context.Context, job Job) error {
+	name := logutil.GetSubsystem(ctx)
+
 	span, ctx := tracer.StartSpanFromContext(
 		ctx, "runutil.job",
 		tracer.Tag(ext.SpanKind, ext.SpanKindInternal),
-		tracer.Tag(ext.ResourceName, logutil.GetSubsystem(ctx)),
+		tracer.Tag(ext.ResourceName, name),
 	)
-	err := w.job.RunOnce(ctx)
+
+	inFlight := instJobInFlight.WithLabelValues(name)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	clock := ClockFromContext(ctx)
+	start := clock.Now()
+
+	// The span must also be finished, when the job panics. Recovering here
+	// would drop the stack trace of the panic, therefore it only gets
+	// detected.
+	finished := false
+	defer func() {
+		if !finished {
+			observeJob(name, start, clock.Now(), errJobPanicked)
+			span.Finish(tracer.WithError(errJobPanicked))
+		}
+	}()
+
+	err := job.RunOnce(ctx)
 	HealthCheckpoint(ctx, err)
+	observeJob(name, start, clock.Now(), err)
+	finished = true
 
 	if err != nil {
 		span.Finish(tracer.WithError(err))
@@ -82,3 +338,18 @@ func (w jobWorker) runOnce(ctx context.Context) error {
 
 	return nil
 }
+
+func observeJob(name string, start, now time.Time, err error) {
+	var (
+		result = "success"
+		last   = instJobLastSuccessTimestamp
+	)
+
+	if err != nil {
+		result = "failure"
+		last = instJobLastFailureTimestamp
+	}
+
+	instJobDurationSeconds.WithLabelValues(name, result).Observe(now.Sub(start).Seconds())
+	last.WithLabelValues(name).Set(float64(now.UnixMilli()) / 1000)
+}
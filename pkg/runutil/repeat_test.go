@@ -0,0 +1,180 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunJobMetrics(t *testing.T) {
+	ctx := logutil.Start(context.Background(), "test-job-metrics")
+	name := logutil.GetSubsystem(ctx)
+
+	before := time.Now()
+
+	require.NoError(t, runJob(ctx, JobFunc(func(ctx context.Context) error {
+		assert.Equal(t, 1., metricValue(t, instJobInFlight.WithLabelValues(name)))
+		return nil
+	})))
+
+	assert.Equal(t, 0., metricValue(t, instJobInFlight.WithLabelValues(name)))
+	assert.GreaterOrEqual(t, metricValue(t, instJobLastSuccessTimestamp.WithLabelValues(name)),
+		float64(before.Unix()))
+	assert.Equal(t, 0., metricValue(t, instJobLastFailureTimestamp.WithLabelValues(name)))
+
+	require.Error(t, runJob(ctx, JobFunc(func(ctx context.Context) error {
+		return errors.New("fail")
+	})))
+
+	assert.GreaterOrEqual(t, metricValue(t, instJobLastFailureTimestamp.WithLabelValues(name)),
+		float64(before.Unix()))
+	assert.Equal(t, 1., metricValue(t, instJobDurationSeconds.WithLabelValues(name, "success").(prometheus.Metric)))
+	assert.Equal(t, 1., metricValue(t, instJobDurationSeconds.WithLabelValues(name, "failure").(prometheus.Metric)))
+}
+
+func TestRetryJobAttemptMetrics(t *testing.T) {
+	ctx := logutil.Start(context.Background(), "test-retry-metrics")
+	name := logutil.GetSubsystem(ctx)
+
+	var calls int
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("fail")
+		}
+		return nil
+	}), StaticBackoff{Sleep: time.Millisecond})
+
+	require.NoError(t, job.RunOnce(ctx))
+	assert.Equal(t, 2., metricValue(t, instJobAttemptsTotal.WithLabelValues(name, "failure")))
+	assert.Equal(t, 1., metricValue(t, instJobAttemptsTotal.WithLabelValues(name, "success")))
+}
+
+func TestRepeatRunTimeout(t *testing.T) {
+	ctx := logutil.Start(context.Background(), "test-repeat-timeout")
+	timeouts := instJobTimeoutsTotal.WithLabelValues(logutil.GetSubsystem(ctx))
+	before := metricValue(t, timeouts)
+
+	worker := Repeat(time.Hour, JobFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), WithStartImmediately(), WithRunTimeout(10*time.Millisecond))
+
+	err := worker.Run(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, before+1, metricValue(t, timeouts))
+}
+
+func TestRepeatContinueOnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = logutil.Start(ctx, "test-repeat-continue")
+	name := logutil.GetSubsystem(ctx)
+
+	var calls atomic.Int32
+	worker := Repeat(time.Millisecond, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return errors.New("fail")
+	}), WithContinueOnError())
+
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, time.Millisecond)
+	assert.GreaterOrEqual(t, metricValue(t, instJobIgnoredErrorsTotal.WithLabelValues(name)), 2.)
+
+	cancel()
+	<-done
+}
+
+func TestRepeatOverrunPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy  OverrunPolicy
+		action  string
+		running int32
+	}{
+		{policy: OverrunSkip, action: "skip", running: 1},
+		{policy: OverrunQueue, action: "queue", running: 1},
+		{policy: OverrunConcurrent, action: "concurrent", running: 2},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ctx = logutil.Start(ctx, "test-repeat-overrun-"+tc.action)
+			name := logutil.GetSubsystem(ctx)
+
+			var (
+				running, maxRunning, calls atomic.Int32
+				release                    = make(chan struct{})
+			)
+
+			worker := Repeat(time.Millisecond, JobFunc(func(ctx context.Context) error {
+				n := calls.Add(1)
+				if n > 2 {
+					// Only the first two runs are of interest.
+					return nil
+				}
+
+				r := running.Add(1)
+				defer running.Add(-1)
+				if r > maxRunning.Load() {
+					maxRunning.Store(r)
+				}
+
+				select {
+				case <-release:
+				case <-ctx.Done():
+				}
+				return nil
+			}), WithOverrunPolicy(tc.policy))
+
+			overruns := instJobOverrunsTotal.WithLabelValues(name, tc.action)
+			before := metricValue(t, overruns)
+
+			done := make(chan error)
+			go func() { done <- worker.Run(ctx) }()
+
+			require.Eventually(t, func() bool { return metricValue(t, overruns) > before }, time.Second, time.Millisecond)
+			require.Eventually(t, func() bool { return maxRunning.Load() == tc.running }, time.Second, time.Millisecond)
+			if tc.running == 1 {
+				assert.Equal(t, int32(1), calls.Load())
+			}
+
+			close(release)
+			require.Eventually(t, func() bool { return calls.Load() > 2 }, time.Second, time.Millisecond,
+				"schedule must continue after the overrun")
+
+			cancel()
+			require.NoError(t, <-done)
+		})
+	}
+}
+
+// metricValue returns the value of a counter or gauge or the sample count of a
+// histogram.
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	t.Helper()
+
+	var pb dto.Metric
+	require.NoError(t, m.Write(&pb))
+
+	switch {
+	case pb.Counter != nil:
+		return pb.Counter.GetValue()
+	case pb.Gauge != nil:
+		return pb.Gauge.GetValue()
+	case pb.Histogram != nil:
+		return float64(pb.Histogram.GetSampleCount())
+	default:
+		t.Fatalf("unsupported metric type: %v", &pb)
+		return 0
+	}
+}
@@ -2,48 +2,291 @@ package runutil
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
 )
 
+var instJobAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: PromNamespace,
+	Subsystem: promJobSubsystem,
+	Name:      "attempts_total",
+}, []string{"worker_name", "result"})
+
+// ErrRetriesExhausted is returned by [RetryJob] and [Retry], when the limits
+// of [WithMaxAttempts] or [WithMaxElapsed] are reached. The error of the last
+// attempt is wrapped as well.
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as non-retryable. [RetryJob] and [Retry] stop
+// immediately and return the original error, when they encounter it. It
+// returns nil, if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// RetryAttempt describes a failed attempt and is passed to the hooks of
+// [WithRetryHook].
+type RetryAttempt struct {
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+
+	// Err is the error of the attempt.
+	Err error
+
+	// Wait is the backoff duration before the next attempt. It is zero, if
+	// there is no further attempt.
+	Wait time.Duration
+
+	// Final is true, if no further attempt will be made.
+	Final bool
+}
+
+type retryPolicy struct {
+	maxAttempts    int
+	maxElapsed     time.Duration
+	attemptTimeout time.Duration
+	retryable      func(error) bool
+	hooks          []func(context.Context, RetryAttempt)
+}
+
+type RetryOption func(*retryPolicy)
+
+// WithMaxAttempts limits the number of attempts, including the first one.
+// Zero means no limit, which is the default.
+//
+// For [Retry] only consecutive failures count, because a worker that exited
+// without error resets the attempts.
+func WithMaxAttempts(n int) RetryOption {
+	return func(p *retryPolicy) {
+		p.maxAttempts = n
+	}
+}
+
+// WithMaxElapsed stops retrying, if the next attempt would start after the
+// given duration since the first attempt. Zero means no limit, which is the
+// default.
+//
+// For [Retry] the duration is measured since the first of the consecutive
+// failures.
+func WithMaxElapsed(d time.Duration) RetryOption {
+	return func(p *retryPolicy) {
+		p.maxElapsed = d
+	}
+}
+
+// WithAttemptTimeout cancels the context of a single attempt after the given
+// duration. A timed out attempt counts as failed and gets retried. It only
+// applies to [RetryJob], since workers are supposed to run indefinitely.
+func WithAttemptTimeout(d time.Duration) RetryOption {
+	return func(p *retryPolicy) {
+		p.attemptTimeout = d
+	}
+}
+
+// WithRetryClassifier sets a function that decides whether an error should be
+// retried. Errors marked with [Permanent] are never retried, regardless of the
+// classifier. By default all errors are retried.
+func WithRetryClassifier(fn func(error) bool) RetryOption {
+	return func(p *retryPolicy) {
+		p.retryable = fn
+	}
+}
+
+// WithRetryableErrors only retries errors that match one of the given errors
+// with [errors.Is]. All other errors are returned immediately.
+func WithRetryableErrors(errs ...error) RetryOption {
+	return WithRetryClassifier(func(err error) bool {
+		for _, target := range errs {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithRetryHook adds a function that is called after every failed attempt, eg
+// for metrics. It is called synchronously and therefore should not block.
+func WithRetryHook(fn func(ctx context.Context, attempt RetryAttempt)) RetryOption {
+	return func(p *retryPolicy) {
+		p.hooks = append(p.hooks, fn)
+	}
+}
+
+func newRetryPolicy(opts []RetryOption) *retryPolicy {
+	p := new(retryPolicy)
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+// failed classifies the error of a failed attempt. It returns the duration to
+// wait before the next attempt and the error that should be returned, if there
+// should be no further attempt.
+func (p *retryPolicy) failed(ctx context.Context, bo Backoff, attempt int, start time.Time, err error) (time.Duration, error) {
+	var (
+		final   error
+		wait    time.Duration
+		permErr *permanentError
+	)
+
+	switch {
+	case errors.As(err, &permErr):
+		final = permErr.err
+	case p.retryable != nil && !p.retryable(err):
+		final = err
+	case p.maxAttempts > 0 && attempt >= p.maxAttempts:
+		final = fmt.Errorf("%w after %d attempts: %w", ErrRetriesExhausted, attempt, err)
+	default:
+		wait = bo.Duration(attempt)
+		if wait == BackoffStop {
+			wait = 0
+			final = fmt.Errorf("%w after %d attempts: %w", ErrRetriesExhausted, attempt, err)
+			break
+		}
+
+		if d, ok := retryAfterOf(err); ok {
+			wait = d
+		}
+
+		if p.maxElapsed > 0 && ClockFromContext(ctx).Now().Sub(start)+wait > p.maxElapsed {
+			wait = 0
+			final = fmt.Errorf("%w after %s: %w", ErrRetriesExhausted, p.maxElapsed, err)
+		}
+	}
+
+	for _, hook := range p.hooks {
+		hook(ctx, RetryAttempt{
+			Attempt: attempt,
+			Err:     err,
+			Wait:    wait,
+			Final:   final != nil,
+		})
+	}
+
+	return wait, final
+}
+
 // RetryJob retries a Job with backoff until it succeeds or the context is
 // cancelled. Unlike Retry, this wraps a single-execution Job and returns nil
 // on success. This is useful for retrying inside a DistributedRepeat loop
 // where the retry should happen while still holding the distributed lock.
-func RetryJob(job Job, bo Backoff) Job {
+//
+// The options define a policy to stop retrying earlier. Errors marked with
+// [Permanent] are always returned immediately. Errors with a delay from
+// [RetryAfter] are retried after that delay instead of the backoff duration.
+//
+// Each attempt is counted in the Prometheus counter
+// rebuy_go_sdk_job_attempts_total.
+func RetryJob(job Job, bo Backoff, opts ...RetryOption) Job {
+	policy := newRetryPolicy(opts)
+
 	return JobFunc(func(ctx context.Context) error {
-		var attempt int
+		var (
+			attempt int
+			start   = ClockFromContext(ctx).Now()
+		)
+
+		name := logutil.GetSubsystem(ctx)
+
 		for ctx.Err() == nil {
-			err := job.RunOnce(ctx)
+			err := policy.runAttempt(ctx, job)
 			if err == nil {
+				instJobAttemptsTotal.WithLabelValues(name, "success").Inc()
 				return nil
 			}
 
+			instJobAttemptsTotal.WithLabelValues(name, "failure").Inc()
+
+			if ctx.Err() != nil {
+				break
+			}
+
 			attempt++
 			logutil.Get(ctx).Warn("job failed", "attempt", attempt, "error", err)
 
-			Wait(ctx, bo.Duration(attempt))
+			wait, final := policy.failed(ctx, bo, attempt, start, err)
+			if final != nil {
+				return final
+			}
+
+			Wait(ctx, wait)
 		}
 
 		return ctx.Err()
 	})
 }
 
+func (p *retryPolicy) runAttempt(ctx context.Context, job Job) error {
+	if p.attemptTimeout <= 0 {
+		return job.RunOnce(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.attemptTimeout)
+	defer cancel()
+
+	return job.RunOnce(ctx)
+}
+
 // Retry restarts a Worker forever when it exists. This happens regardless of
 // whether the worker returns an error or nil. The worker only stops with
 // restarting, when the context gets cancelled.
-func Retry(worker Worker, bo Backoff) Worker {
+//
+// The options define a policy to stop restarting after errors. In this case
+// the error gets returned. Errors marked with [Permanent] are always returned
+// immediately.
+func Retry(worker Worker, bo Backoff, opts ...RetryOption) Worker {
+	policy := newRetryPolicy(opts)
+
 	return WorkerFunc(func(ctx context.Context) error {
-		var attempt int
+		var (
+			attempt int
+			wait    time.Duration
+			start   time.Time
+		)
+
 		for ctx.Err() == nil {
-			Wait(ctx, bo.Duration(attempt))
+			Wait(ctx, wait)
 
 			err := worker.Run(ctx)
-			if err != nil {
-				attempt += 1
-				logutil.Get(ctx).Warn("worker failed", "attempt", attempt, "error", err)
-			} else {
+			if err == nil {
 				attempt = 0
+				wait = 0
+				continue
+			}
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			if attempt == 0 {
+				start = ClockFromContext(ctx).Now()
+			}
+
+			attempt += 1
+			logutil.Get(ctx).Warn("worker failed", "attempt", attempt, "error", err)
+
+			var final error
+			wait, final = policy.failed(ctx, bo, attempt, start, err)
+			if final != nil {
+				return final
 			}
 		}
 
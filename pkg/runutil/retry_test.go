@@ -2,6 +2,7 @@ package runutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -91,3 +92,193 @@ func TestRetryJobBackoffApplied(t *testing.T) {
 		t.Fatalf("expected at least 90ms elapsed, got %v", elapsed)
 	}
 }
+
+func TestRetryJobMaxAttempts(t *testing.T) {
+	var calls int
+	errFail := fmt.Errorf("fail")
+
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		return errFail
+	}), StaticBackoff{Sleep: time.Millisecond}, WithMaxAttempts(3))
+
+	err := job.RunOnce(context.Background())
+	if !errors.Is(err, ErrRetriesExhausted) || !errors.Is(err, errFail) {
+		t.Fatalf("expected exhausted error wrapping the last error, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryJobMaxElapsed(t *testing.T) {
+	var calls int
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("fail")
+	}), StaticBackoff{Sleep: 30 * time.Millisecond}, WithMaxElapsed(50*time.Millisecond))
+
+	err := job.RunOnce(context.Background())
+	if !errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("expected ErrRetriesExhausted, got %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryJobPermanent(t *testing.T) {
+	var calls int
+	errFatal := fmt.Errorf("fatal")
+
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		return Permanent(errFatal)
+	}), StaticBackoff{Sleep: time.Millisecond})
+
+	err := job.RunOnce(context.Background())
+	if err != errFatal {
+		t.Fatalf("expected the unwrapped permanent error, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryJobRetryableErrors(t *testing.T) {
+	errTemporary := fmt.Errorf("temporary")
+	errOther := fmt.Errorf("other")
+
+	var calls int
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("wrapped: %w", errTemporary)
+		}
+		return errOther
+	}), StaticBackoff{Sleep: time.Millisecond}, WithRetryableErrors(errTemporary))
+
+	err := job.RunOnce(context.Background())
+	if err != errOther {
+		t.Fatalf("expected non-retryable error, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryJobAttemptTimeout(t *testing.T) {
+	var calls int
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}), StaticBackoff{Sleep: time.Millisecond}, WithAttemptTimeout(10*time.Millisecond))
+
+	err := job.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryJobHook(t *testing.T) {
+	var attempts []RetryAttempt
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		return fmt.Errorf("fail")
+	}), StaticBackoff{Sleep: time.Millisecond},
+		WithMaxAttempts(2),
+		WithRetryHook(func(_ context.Context, a RetryAttempt) {
+			attempts = append(attempts, a)
+		}),
+	)
+
+	_ = job.RunOnce(context.Background())
+
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 hook calls, got %d", len(attempts))
+	}
+
+	if attempts[0].Attempt != 1 || attempts[0].Final || attempts[0].Wait != time.Millisecond {
+		t.Fatalf("unexpected first attempt: %+v", attempts[0])
+	}
+
+	if attempts[1].Attempt != 2 || !attempts[1].Final || attempts[1].Wait != 0 {
+		t.Fatalf("unexpected second attempt: %+v", attempts[1])
+	}
+}
+
+func TestRetryWorkerPermanent(t *testing.T) {
+	errFatal := fmt.Errorf("fatal")
+
+	var calls int
+	worker := Retry(WorkerFunc(func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return nil
+		}
+		return Permanent(errFatal)
+	}), StaticBackoff{Sleep: time.Millisecond})
+
+	err := worker.Run(context.Background())
+	if err != errFatal {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryJobBackoffStop(t *testing.T) {
+	var calls int
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("fail")
+	}), BackoffWithMaxAttempts{Backoff: StaticBackoff{Sleep: time.Millisecond}, MaxAttempts: 2})
+
+	err := job.RunOnce(context.Background())
+	if !errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("expected ErrRetriesExhausted, got %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryJobRetryAfter(t *testing.T) {
+	var attempts []RetryAttempt
+	job := RetryJob(JobFunc(func(ctx context.Context) error {
+		if len(attempts) == 0 {
+			return RetryAfter(fmt.Errorf("rate limited"), 20*time.Millisecond)
+		}
+		return nil
+	}), StaticBackoff{Sleep: time.Hour}, WithRetryHook(func(_ context.Context, a RetryAttempt) {
+		attempts = append(attempts, a)
+	}))
+
+	start := time.Now()
+	err := job.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the retry-after delay instead of the backoff, waited %v", elapsed)
+	}
+
+	if len(attempts) != 1 || attempts[0].Wait != 20*time.Millisecond {
+		t.Fatalf("unexpected attempts: %+v", attempts)
+	}
+}
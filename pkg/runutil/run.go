@@ -21,39 +21,22 @@ var ErrWorkerExitedPrematurely = errors.New("worker exited prematurely")
 //   - Err contains [WorkerExitedPrematurely], if the workers return a nil error
 //     while the context was not cancelled.
 //   - Err contains all errors, returned by the workers.
+//
+// The workers run in a [Supervisor] with the [OneForOne] strategy. The first
+// point only applies to workers with the default restart settings. A
+// [DeclarativeWorker] can change them with its Restart and Optional fields.
 func RunAllWorkers(ctx context.Context, workers ...Worker) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	var wg sync.WaitGroup
-	wg.Add(len(workers))
-
-	var errs collector[error]
-
-	for _, w := range workers {
-		w := w
-		go func() {
-			defer wg.Done()
-			defer cancel()
-			err := w.Run(ctx)
-			if err != nil {
-				errs.Append(err)
-			} else if ctx.Err() == nil {
-				// It means that the works exited itself, if the worker returns
-				// nil and the context was not cancelled yet.
-				errs.Append(ErrWorkerExitedPrematurely)
-			}
-			// Otherwise the Context was cancelled and the worker did not
-			// return an error, which means it shut down gracefully.
-		}()
+	children := make([]ChildSpec, len(workers))
+	for i, w := range workers {
+		children[i] = childSpecOf(w)
 	}
 
-	wg.Wait()
-
-	return errors.Join(errs.Result()...)
+	return NewSupervisor(children).Run(ctx)
 }
 
-// RunAllJobs runs all jobs in parallel and return their errors.
+// RunAllJobs runs all jobs in parallel and return their errors. It starts a
+// goroutine for each job, therefore [ForEach] is better suited for a large
+// number of jobs.
 func RunAllJobs(ctx context.Context, jobs ...Job) error {
 	var wg sync.WaitGroup
 	wg.Add(len(jobs))
@@ -0,0 +1,462 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+// ErrRestartIntensityExceeded is returned by a [Supervisor], when its children
+// got restarted more often than allowed by [WithRestartIntensity].
+var ErrRestartIntensityExceeded = errors.New("restart intensity exceeded")
+
+// RestartPolicy defines whether a [Supervisor] restarts a child after it
+// exited.
+type RestartPolicy int
+
+const (
+	// RestartTemporary never restarts the child. This is the default and
+	// matches the behaviour of [RunAllWorkers].
+	RestartTemporary RestartPolicy = iota
+
+	// RestartTransient restarts the child only, if it exited with an error.
+	RestartTransient
+
+	// RestartPermanent always restarts the child, regardless of its error.
+	RestartPermanent
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartTemporary:
+		return "temporary"
+	case RestartTransient:
+		return "transient"
+	case RestartPermanent:
+		return "permanent"
+	default:
+		return fmt.Sprintf("RestartPolicy(%d)", int(p))
+	}
+}
+
+func (p RestartPolicy) restarts(err error) bool {
+	switch p {
+	case RestartPermanent:
+		return true
+	case RestartTransient:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// SupervisorStrategy defines which children a [Supervisor] restarts, when a
+// single child exited.
+type SupervisorStrategy int
+
+const (
+	// OneForOne only restarts the child that exited.
+	OneForOne SupervisorStrategy = iota
+
+	// OneForAll stops all other children and restarts them together with the
+	// child that exited. This is useful for children that depend on each
+	// other.
+	OneForAll
+)
+
+func (s SupervisorStrategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one-for-one"
+	case OneForAll:
+		return "one-for-all"
+	default:
+		return fmt.Sprintf("SupervisorStrategy(%d)", int(s))
+	}
+}
+
+// ChildSpec describes a child of a [Supervisor].
+type ChildSpec struct {
+	Worker Worker
+
+	// Name is used for logging. It is optional.
+	Name string
+
+	// Restart defines whether the child gets restarted after it exited.
+	Restart RestartPolicy
+
+	// Optional marks a child as non-critical. By default, the supervisor stops
+	// all children and exits as soon as a child exits without being
+	// restarted. An optional child just stays stopped instead.
+	Optional bool
+
+	// ShutdownPhase defines the order in which children get stopped on
+	// shutdown. Children with a lower phase get cancelled first and the next
+	// phase only gets cancelled after they exited. For example, an HTTP
+	// server that produces rows for a batch writer should have a lower phase
+	// than the writer, so all rows get flushed.
+	ShutdownPhase int
+}
+
+func (c ChildSpec) name(i int) string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	return fmt.Sprintf("#%d", i)
+}
+
+// Supervisor runs multiple children and restarts them according to their
+// [RestartPolicy]. It is a [Worker] itself and therefore can be nested to
+// build a supervision tree. See [NewSupervisor].
+type Supervisor struct {
+	children []ChildSpec
+	strategy SupervisorStrategy
+
+	maxRestarts   int
+	restartPeriod time.Duration
+	backoff       Backoff
+
+	defaultPhaseTimeout time.Duration
+	phaseTimeouts       map[int]time.Duration
+}
+
+type SupervisorOption func(*Supervisor)
+
+// WithStrategy sets the restart strategy. Defaults to [OneForOne].
+func WithStrategy(strategy SupervisorStrategy) SupervisorOption {
+	return func(s *Supervisor) {
+		s.strategy = strategy
+	}
+}
+
+// WithRestartIntensity stops the supervisor with
+// [ErrRestartIntensityExceeded], if there are more than maxRestarts restarts
+// within the given period. This prevents crash loops from going unnoticed.
+// Defaults to 10 restarts per minute.
+func WithRestartIntensity(maxRestarts int, period time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxRestarts = maxRestarts
+		s.restartPeriod = period
+	}
+}
+
+// WithRestartBackoff delays restarts with the given [Backoff]. The attempt
+// passed to the backoff is the number of restarts within the period of
+// [WithRestartIntensity]. By default restarts happen immediately.
+func WithRestartBackoff(bo Backoff) SupervisorOption {
+	return func(s *Supervisor) {
+		s.backoff = bo
+	}
+}
+
+// WithShutdownTimeout sets the time the supervisor waits for the children of a
+// single shutdown phase to exit, before it logs the blocking children and
+// continues with the next phase. The blocking children do not get abandoned,
+// the supervisor still waits for them to exit before it returns. Defaults to
+// 30s.
+func WithShutdownTimeout(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.defaultPhaseTimeout = d
+	}
+}
+
+// WithShutdownPhaseTimeout overrides the timeout of [WithShutdownTimeout] for
+// a single shutdown phase.
+func WithShutdownPhaseTimeout(phase int, d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.phaseTimeouts[phase] = d
+	}
+}
+
+// NewSupervisor creates an Erlang-style [Supervisor] for the given children.
+//
+// Behaviour:
+//   - A child that exits gets restarted according to its [RestartPolicy] and
+//     the [SupervisorStrategy].
+//   - A critical child that exits without being restarted stops all other
+//     children. The supervisor returns its error or
+//     [ErrWorkerExitedPrematurely], if the error was nil.
+//   - An optional child that exits without being restarted only gets logged.
+//   - The supervisor exits, when the context got cancelled and all children
+//     exited or when all children exited without being restarted.
+//   - Errors of children that get returned after the context got cancelled
+//     are passed through.
+//   - On shutdown, the children get cancelled in ascending order of their
+//     ShutdownPhase. A phase gets cancelled as soon as all children of the
+//     previous phase exited or its timeout expired.
+func NewSupervisor(children []ChildSpec, opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		children:            children,
+		maxRestarts:         10,
+		restartPeriod:       time.Minute,
+		defaultPhaseTimeout: 30 * time.Second,
+		phaseTimeouts:       map[int]time.Duration{},
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+type childExit struct {
+	index int
+	err   error
+}
+
+// supervision contains the state of a single [Supervisor.Run] call.
+type supervision struct {
+	*Supervisor
+
+	ctx      context.Context
+	childCtx context.Context
+	exits    chan childExit
+	running  map[int]context.CancelFunc
+	stopping map[int]bool
+	pending  []int
+	restarts []time.Time
+	errs     []error
+
+	// phases contains the remaining shutdown phases in ascending order. The
+	// first one is the phase that currently gets stopped.
+	phases       []int
+	phaseTimeout <-chan time.Time
+}
+
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &supervision{
+		Supervisor: s,
+		ctx:        ctx,
+		// The children must not be cancelled together with the context,
+		// because they get stopped phase by phase.
+		childCtx: context.WithoutCancel(ctx),
+		// Each child runs at most once at a time, therefore the buffer
+		// makes sure that no child blocks on exit.
+		exits:    make(chan childExit, len(s.children)),
+		running:  map[int]context.CancelFunc{},
+		stopping: map[int]bool{},
+	}
+
+	for i := range s.children {
+		r.start(i, 0)
+	}
+
+	shutdown := ctx.Done()
+	for len(r.running) > 0 {
+		select {
+		case exit := <-r.exits:
+			r.handleExit(exit, cancel)
+
+		case <-shutdown:
+			shutdown = nil
+			r.beginShutdown()
+
+		case <-r.phaseTimeout:
+			r.logger(-1).Warn("shutdown phase timed out, continuing with next phase",
+				"phase", r.phases[0], "blocking", r.blocking())
+			r.phases = r.phases[1:]
+			r.phaseTimeout = nil
+			r.stopPhases()
+		}
+	}
+
+	return errors.Join(r.errs...)
+}
+
+func (r *supervision) start(i int, delay time.Duration) {
+	childCtx, childCancel := context.WithCancel(r.childCtx)
+	r.running[i] = childCancel
+
+	go func() {
+		if delay > 0 {
+			Wait(childCtx, delay)
+			if childCtx.Err() != nil {
+				r.exits <- childExit{index: i}
+				return
+			}
+		}
+
+		r.exits <- childExit{index: i, err: r.children[i].Worker.Run(childCtx)}
+	}()
+}
+
+func (r *supervision) logger(i int) *slog.Logger {
+	logger := logutil.Get(r.ctx)
+	if i < 0 {
+		return logger
+	}
+
+	child := r.children[i]
+	return logger.With("child", child.name(i), "restart", child.Restart.String())
+}
+
+func (r *supervision) handleExit(exit childExit, cancel context.CancelFunc) {
+	r.running[exit.index]()
+	delete(r.running, exit.index)
+
+	if r.ctx.Err() != nil {
+		if exit.err != nil {
+			r.errs = append(r.errs, exit.err)
+		}
+		r.stopPhases()
+		return
+	}
+
+	if r.stopping[exit.index] {
+		// The child was stopped by the supervisor to restart all children.
+		delete(r.stopping, exit.index)
+		if len(r.stopping) == 0 {
+			delay := r.restartDelay(len(r.restarts))
+			for _, i := range r.pending {
+				r.start(i, delay)
+			}
+			r.pending = nil
+		}
+		return
+	}
+
+	child := r.children[exit.index]
+	logger := r.logger(exit.index)
+
+	if !child.Restart.restarts(exit.err) {
+		if child.Optional {
+			logger.Warn("optional worker exited", "error", exit.err)
+			return
+		}
+
+		if exit.err == nil {
+			exit.err = ErrWorkerExitedPrematurely
+		}
+
+		r.errs = append(r.errs, exit.err)
+		cancel()
+		return
+	}
+
+	now := ClockFromContext(r.ctx).Now()
+	r.restarts = append(r.restarts, now)
+	for len(r.restarts) > 0 && now.Sub(r.restarts[0]) > r.restartPeriod {
+		r.restarts = r.restarts[1:]
+	}
+
+	if len(r.restarts) > r.maxRestarts {
+		cause := exit.err
+		if cause == nil {
+			cause = ErrWorkerExitedPrematurely
+		}
+
+		logger.Error("worker restarted too often", "error", cause,
+			"max-restarts", r.maxRestarts, "period", r.restartPeriod)
+		r.errs = append(r.errs, fmt.Errorf("%w: %d restarts within %s: %w",
+			ErrRestartIntensityExceeded, len(r.restarts), r.restartPeriod, cause))
+		cancel()
+		return
+	}
+
+	logger.Warn("restarting worker", "error", exit.err, "strategy", r.strategy)
+
+	if r.strategy != OneForAll || len(r.running) == 0 {
+		r.start(exit.index, r.restartDelay(len(r.restarts)))
+		return
+	}
+
+	r.pending = append(r.pending, exit.index)
+	for i, childCancel := range r.running {
+		if r.stopping[i] {
+			continue
+		}
+		r.stopping[i] = true
+		r.pending = append(r.pending, i)
+		childCancel()
+	}
+}
+
+func (r *supervision) beginShutdown() {
+	for i := range r.running {
+		r.phases = append(r.phases, r.children[i].ShutdownPhase)
+	}
+
+	slices.Sort(r.phases)
+	r.phases = slices.Compact(r.phases)
+
+	r.stopPhases()
+}
+
+// stopPhases cancels all children of the current shutdown phase and proceeds
+// with the next phase, as soon as all of them exited.
+func (r *supervision) stopPhases() {
+	for len(r.phases) > 0 {
+		phase := r.phases[0]
+
+		for i, childCancel := range r.running {
+			if r.children[i].ShutdownPhase == phase {
+				childCancel()
+			}
+		}
+
+		if len(r.blocking()) > 0 {
+			if r.phaseTimeout == nil {
+				r.logger(-1).Debug("waiting for shutdown phase", "phase", phase)
+				r.phaseTimeout = ClockFromContext(r.ctx).After(r.shutdownTimeout(phase))
+			}
+			return
+		}
+
+		r.phases = r.phases[1:]
+		r.phaseTimeout = nil
+	}
+}
+
+// blocking returns the names of the running children of the current shutdown
+// phase.
+func (r *supervision) blocking() []string {
+	var names []string
+	for i := range r.running {
+		if len(r.phases) > 0 && r.children[i].ShutdownPhase == r.phases[0] {
+			names = append(names, r.children[i].name(i))
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+func (s *Supervisor) shutdownTimeout(phase int) time.Duration {
+	d, ok := s.phaseTimeouts[phase]
+	if ok {
+		return d
+	}
+
+	return s.defaultPhaseTimeout
+}
+
+// childSpecOf creates a [ChildSpec] with the restart settings of a
+// [DeclarativeWorker], if the worker is one.
+func childSpecOf(w Worker) ChildSpec {
+	switch dw := w.(type) {
+	case DeclarativeWorker:
+		return dw.childSpec()
+	case *DeclarativeWorker:
+		return dw.childSpec()
+	default:
+		return ChildSpec{Worker: w}
+	}
+}
+
+func (s *Supervisor) restartDelay(attempt int) time.Duration {
+	if s.backoff == nil {
+		return 0
+	}
+
+	return max(s.backoff.Duration(attempt), 0)
+}
@@ -0,0 +1,237 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisorRestartPolicies(t *testing.T) {
+	errFail := errors.New("fail")
+
+	cases := []struct {
+		name   string
+		policy RestartPolicy
+		err    error
+		want   int32
+	}{
+		{name: "temporary-error", policy: RestartTemporary, err: errFail, want: 1},
+		{name: "temporary-nil", policy: RestartTemporary, err: nil, want: 1},
+		{name: "transient-error", policy: RestartTransient, err: errFail, want: 4},
+		{name: "transient-nil", policy: RestartTransient, err: nil, want: 1},
+		{name: "permanent-error", policy: RestartPermanent, err: errFail, want: 4},
+		{name: "permanent-nil", policy: RestartPermanent, err: nil, want: 4},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			s := NewSupervisor([]ChildSpec{{
+				Worker: WorkerFunc(func(ctx context.Context) error {
+					calls.Add(1)
+					return tc.err
+				}),
+				Restart: tc.policy,
+			}}, WithRestartIntensity(3, time.Minute))
+
+			err := s.Run(context.Background())
+			require.Error(t, err)
+			assert.Equal(t, tc.want, calls.Load())
+
+			if tc.want > 1 {
+				assert.ErrorIs(t, err, ErrRestartIntensityExceeded)
+			}
+		})
+	}
+}
+
+func TestSupervisorOptionalChild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	optionalDone := make(chan struct{})
+
+	s := NewSupervisor([]ChildSpec{
+		{
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				defer close(optionalDone)
+				return errors.New("optional failed")
+			}),
+			Optional: true,
+		},
+		{
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				<-optionalDone
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+				<-ctx.Done()
+				return nil
+			}),
+		},
+	})
+
+	err := s.Run(ctx)
+	require.NoError(t, err)
+}
+
+func TestSupervisorOneForAll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		failingCalls atomic.Int32
+		stableCalls  atomic.Int32
+	)
+
+	s := NewSupervisor([]ChildSpec{
+		{
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				if failingCalls.Add(1) < 3 {
+					time.Sleep(10 * time.Millisecond)
+					return errors.New("fail")
+				}
+
+				<-ctx.Done()
+				return nil
+			}),
+			Restart: RestartTransient,
+		},
+		{
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				stableCalls.Add(1)
+				<-ctx.Done()
+				return nil
+			}),
+			Restart: RestartTransient,
+		},
+	}, WithStrategy(OneForAll))
+
+	go func() {
+		defer cancel()
+		assert.Eventually(t, func() bool {
+			return failingCalls.Load() == 3 && stableCalls.Load() == 3
+		}, time.Second, time.Millisecond)
+	}()
+
+	err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), failingCalls.Load())
+	assert.Equal(t, int32(3), stableCalls.Load())
+}
+
+func TestSupervisorRestartBackoff(t *testing.T) {
+	var calls atomic.Int32
+
+	s := NewSupervisor([]ChildSpec{{
+		Worker: WorkerFunc(func(ctx context.Context) error {
+			calls.Add(1)
+			return errors.New("fail")
+		}),
+		Restart: RestartPermanent,
+	}},
+		WithRestartIntensity(2, time.Minute),
+		WithRestartBackoff(StaticBackoff{Sleep: 30 * time.Millisecond}),
+	)
+
+	start := time.Now()
+	err := s.Run(context.Background())
+	require.ErrorIs(t, err, ErrRestartIntensityExceeded)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+func TestRunAllWorkersDeclarativeRestart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+
+	err := RunAllWorkers(ctx,
+		DeclarativeWorker{
+			Name: "restarting",
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				if calls.Add(1) < 3 {
+					return nil
+				}
+				cancel()
+				return nil
+			}),
+			Restart: RestartPermanent,
+		},
+		WorkerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestSupervisorShutdownPhases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var order collector[string]
+	phaseWorker := func(name string, delay time.Duration) Worker {
+		return WorkerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(delay)
+			order.Append(name)
+			return nil
+		})
+	}
+
+	s := NewSupervisor([]ChildSpec{
+		{Worker: phaseWorker("consumer", 0), ShutdownPhase: 2},
+		{Worker: phaseWorker("producer-slow", 30*time.Millisecond), ShutdownPhase: 1},
+		{Worker: phaseWorker("producer", 0), ShutdownPhase: 1},
+		{Worker: phaseWorker("server", 10*time.Millisecond)},
+	})
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err := s.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"server", "producer", "producer-slow", "consumer"}, order.Result())
+}
+
+func TestSupervisorShutdownPhaseTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release := make(chan struct{})
+	var order collector[string]
+
+	s := NewSupervisor([]ChildSpec{
+		{
+			Name: "blocking",
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				<-ctx.Done()
+				<-release
+				order.Append("blocking")
+				return nil
+			}),
+		},
+		{
+			Name: "late",
+			Worker: WorkerFunc(func(ctx context.Context) error {
+				<-ctx.Done()
+				order.Append("late")
+				close(release)
+				return nil
+			}),
+			ShutdownPhase: 1,
+		},
+	}, WithShutdownPhaseTimeout(0, 20*time.Millisecond))
+
+	cancel()
+
+	err := s.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"late", "blocking"}, order.Result())
+}
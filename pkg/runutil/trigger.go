@@ -0,0 +1,161 @@
+package runutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+)
+
+// Trigger is a signal for a worker created with [Triggered]. It is safe for
+// concurrent use and coalesces signals, so firing it never blocks. Create it
+// with [NewTrigger].
+type Trigger struct {
+	ch chan struct{}
+}
+
+// NewTrigger creates a new [Trigger].
+func NewTrigger() *Trigger {
+	return &Trigger{ch: make(chan struct{}, 1)}
+}
+
+// Fire signals the worker that the job should run. Signals that arrive while
+// a signal is still pending are coalesced into a single run.
+func (t *Trigger) Fire() {
+	select {
+	case t.ch <- struct{}{}:
+	default:
+	}
+}
+
+type triggeredWorker struct {
+	trigger  *Trigger
+	job      Job
+	minDelay time.Duration
+	maxDelay time.Duration
+	interval time.Duration
+}
+
+type TriggeredOption func(*triggeredWorker)
+
+// WithDebounce delays the run after a signal until there was no further signal
+// for minDelay, but at most for maxDelay after the first signal. A maxDelay of
+// zero means there is no upper limit, which might delay the run indefinitely
+// with a steady stream of signals.
+func WithDebounce(minDelay, maxDelay time.Duration) TriggeredOption {
+	return func(w *triggeredWorker) {
+		w.minDelay = minDelay
+		w.maxDelay = maxDelay
+	}
+}
+
+// WithFallbackInterval additionally runs the job, if there was no run within
+// the given interval. This is useful when signals might get lost, eg with
+// Redis Pub/Sub.
+func WithFallbackInterval(d time.Duration) TriggeredOption {
+	return func(w *triggeredWorker) {
+		w.interval = d
+	}
+}
+
+// Triggered creates a worker that runs the job every time the [Trigger] fires,
+// eg in reaction to an event from Redis Pub/Sub or Postgres NOTIFY. Like
+// [Repeat], the worker stops when the job returns an error.
+//
+// The job never runs concurrently. Signals that arrive while the job runs
+// result in exactly one rerun after the current run finished.
+//
+// The job can also be triggered, paused and resumed at runtime with its
+// [JobControl].
+func Triggered(trigger *Trigger, job Job, opts ...TriggeredOption) Worker {
+	w := &triggeredWorker{
+		trigger: trigger,
+		job:     job,
+	}
+
+	for _, o := range opts {
+		o(w)
+	}
+
+	return w
+}
+
+func (w *triggeredWorker) Run(ctx context.Context) error {
+	control, unregister := registerJobControl(ctx)
+	defer unregister()
+
+	if w.interval <= 0 {
+		return w.loop(ctx, control, nil, func() {})
+	}
+
+	ticker := ClockFromContext(ctx).NewTicker(w.interval)
+	defer ticker.Stop()
+
+	// Restart the interval after each run, so the fallback only fires if there
+	// was no run for the whole interval.
+	return w.loop(ctx, control, ticker.C(), func() { ticker.Reset(w.interval) })
+}
+
+func (w *triggeredWorker) loop(ctx context.Context, control *JobControl, fallback <-chan time.Time, ran func()) error {
+	for {
+		manual := false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-control.triggered():
+			logutil.Get(ctx).Info("running manually triggered job")
+			manual = true
+		case <-fallback:
+			logutil.Get(ctx).Debug("running job because of fallback interval")
+		case <-w.trigger.ch:
+			if !w.debounce(ctx) {
+				return nil
+			}
+		}
+
+		if !manual && control.skip(ctx) {
+			continue
+		}
+
+		err := control.run(ctx, w.job)
+		if err != nil {
+			return err
+		}
+
+		ran()
+	}
+}
+
+// debounce waits until there were no further signals for the min delay or the
+// max delay passed. It returns false, if the context got cancelled.
+func (w *triggeredWorker) debounce(ctx context.Context) bool {
+	if w.minDelay <= 0 {
+		return true
+	}
+
+	clock := ClockFromContext(ctx)
+
+	var deadline time.Time
+	if w.maxDelay > 0 {
+		deadline = clock.Now().Add(w.maxDelay)
+	}
+
+	timer := clock.NewTimer(w.minDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C():
+			return true
+		case <-w.trigger.ch:
+			d := w.minDelay
+			if !deadline.IsZero() {
+				d = min(d, deadline.Sub(clock.Now()))
+			}
+			timer.Reset(d)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package runutil
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runTriggered(t *testing.T, worker Worker) context.CancelFunc {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	return func() {
+		cancel()
+		require.NoError(t, <-done)
+	}
+}
+
+func TestTriggeredRunsOnSignal(t *testing.T) {
+	trigger := NewTrigger()
+
+	var calls atomic.Int32
+	stop := runTriggered(t, Triggered(trigger, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})))
+	defer stop()
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), calls.Load())
+
+	trigger.Fire()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestTriggeredSinglePendingRerun(t *testing.T) {
+	trigger := NewTrigger()
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+
+	var calls, concurrent, peak atomic.Int32
+	stop := runTriggered(t, Triggered(trigger, JobFunc(func(ctx context.Context) error {
+		n := concurrent.Add(1)
+		defer concurrent.Add(-1)
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+
+		calls.Add(1)
+		started <- struct{}{}
+		<-release
+		return nil
+	})))
+	defer stop()
+
+	trigger.Fire()
+	<-started
+
+	// Fire multiple times during the run.
+	for range 5 {
+		trigger.Fire()
+	}
+
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, int32(1), peak.Load())
+}
+
+func TestTriggeredDebounce(t *testing.T) {
+	trigger := NewTrigger()
+
+	var calls atomic.Int32
+	stop := runTriggered(t, Triggered(trigger, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}), WithDebounce(30*time.Millisecond, time.Hour)))
+	defer stop()
+
+	for range 5 {
+		trigger.Fire()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.Equal(t, int32(0), calls.Load(), "still within quiet period")
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load(), "burst must be coalesced")
+}
+
+func TestTriggeredDebounceMaxDelay(t *testing.T) {
+	trigger := NewTrigger()
+
+	var calls atomic.Int32
+	stop := runTriggered(t, Triggered(trigger, JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}), WithDebounce(20*time.Millisecond, 50*time.Millisecond)))
+	defer stop()
+
+	start := time.Now()
+	for calls.Load() == 0 && time.Since(start) < time.Second {
+		trigger.Fire()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestTriggeredFallbackInterval(t *testing.T) {
+	var calls atomic.Int32
+	stop := runTriggered(t, Triggered(NewTrigger(), JobFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}), WithFallbackInterval(10*time.Millisecond)))
+	defer stop()
+
+	require.Eventually(t, func() bool { return calls.Load() >= 2 }, time.Second, time.Millisecond)
+}
@@ -6,12 +6,12 @@ import (
 )
 
 // Wait is similar to [time.Sleep], but stops blocking when the context gets
-// cancelled.
+// cancelled. It uses the [Clock] of the context.
 func Wait(ctx context.Context, d time.Duration) {
 	select {
 	case <-ctx.Done():
 		return
-	case <-time.After(d):
+	case <-ClockFromContext(ctx).After(d):
 		return
 	}
 }
@@ -2,21 +2,31 @@ package webutil
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
+	"html/template"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
 
 	// instutil import ensures the init function of that package is run, which adds the toolstack metrics
 	_ "github.com/rebuy-de/rebuy-go-sdk/v10/pkg/instutil"
 )
 
 type adminAPIListenAndServeOptions struct {
-	host string
-	port string
+	host            string
+	port            string
+	healthStaleness time.Duration
+
+	adminUsername string
+	adminPassword string
 }
 
 type AdminAPIListenAndServeOption func(*adminAPIListenAndServeOptions)
@@ -33,6 +43,59 @@ func WithHost(host string) AdminAPIListenAndServeOption {
 	}
 }
 
+// WithHealthStaleness sets the window in which workers need to send a health
+// checkpoint (see runutil.HealthCheckpoint) to be considered ready by the
+// /health/ready endpoint. Workers that never sent a checkpoint are not
+// affected. Defaults to zero, which disables the staleness check.
+func WithHealthStaleness(window time.Duration) AdminAPIListenAndServeOption {
+	return func(o *adminAPIListenAndServeOptions) {
+		o.healthStaleness = window
+	}
+}
+
+// WithAdminAuth enables the /jobs endpoints, which allow to trigger, pause and
+// resume scheduled jobs (see runutil.JobControl), and the /log/level endpoint.
+// The endpoints are protected with HTTP basic auth using the given
+// credentials. Without this option or with an empty username or password the
+// endpoints are disabled.
+func WithAdminAuth(username, password string) AdminAPIListenAndServeOption {
+	return func(o *adminAPIListenAndServeOptions) {
+		o.adminUsername = username
+		o.adminPassword = password
+	}
+}
+
+// WithJobControlAuth is the same as WithAdminAuth.
+//
+// Deprecated: The credentials protect more than the job control endpoints.
+// Use WithAdminAuth instead.
+func WithJobControlAuth(username, password string) AdminAPIListenAndServeOption {
+	return WithAdminAuth(username, password)
+}
+
+// AdminAPIListenAndServe starts the admin API in the background. It serves
+// Prometheus metrics, pprof and these health endpoints:
+//
+//   - /health answers OK until the context is cancelled.
+//   - /health/ready additionally fails when any worker is firing or is stale
+//     (see WithHealthStaleness).
+//   - /health/workers returns the health state of all workers as JSON.
+//   - /workers returns the worker dependency graph (see runutil.WorkerGraph)
+//     as JSON or, with the query parameter format=dot, in the Graphviz DOT
+//     format.
+//
+// With WithAdminAuth it also serves these endpoints:
+//
+//   - /jobs lists all scheduled jobs with buttons to control them.
+//   - /jobs/status returns the status of all scheduled jobs as JSON.
+//   - /jobs/trigger, /jobs/pause and /jobs/resume control the job given by the
+//     id query parameter (see runutil.JobStatus). They only accept POST
+//     requests.
+//   - /log/level returns the global log level and the levels of the
+//     subsystems as JSON. A POST request with the query parameter level
+//     changes the global level or, with the additional parameter subsystem,
+//     the level of a subsystem (see logutil.SetSubsystemLevel). The level
+//     "reset" removes the level of a subsystem.
 func AdminAPIListenAndServe(ctx context.Context, opts ...AdminAPIListenAndServeOption) {
 	config := adminAPIListenAndServeOptions{
 		host: "0.0.0.0",
@@ -44,6 +107,30 @@ func AdminAPIListenAndServe(ctx context.Context, opts ...AdminAPIListenAndServeO
 	}
 
 	ctx = logutil.Start(ctx, "admin-api")
+	mux := newAdminMux(ctx, config)
+
+	// The admin api gets a its own context, because we want to delay the
+	// server shutdown as long as possible. The reason for this is that Istio
+	// starts to block all outgoing connections as soon as there is no
+	// listening server anymore. Also a graceful shutdown is not needed for the
+	// admin API, so it is also not necessary to cancel the context.
+	bg := context.Background()
+
+	go func() {
+		serverAddress := net.JoinHostPort(config.host, config.port)
+
+		logutil.Get(ctx).Debug("admin api listening", "address", serverAddress)
+
+		err := ListenAndServeWithContext(bg, serverAddress, mux)
+		if err != nil {
+			logutil.Get(ctx).Error(err.Error())
+		}
+	}()
+}
+
+// newAdminMux creates the handlers of the admin API. See
+// AdminAPIListenAndServe.
+func newAdminMux(ctx context.Context, config adminAPIListenAndServeOptions) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.Handle("/metrics", promhttp.Handler())
@@ -57,6 +144,78 @@ func AdminAPIListenAndServe(ctx context.Context, opts ...AdminAPIListenAndServeO
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintln(w, "OK")
 	})
+	mux.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		if ctx.Err() != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "SHUTTING DOWN")
+			return
+		}
+
+		err := runutil.HealthReady(ctx, config.healthStaleness)
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "OK")
+	})
+	mux.HandleFunc("/health/workers", func(w http.ResponseWriter, r *http.Request) {
+		type workerStatus struct {
+			runutil.HealthStatus
+			Ready bool   `json:"ready"`
+			Error string `json:"error,omitempty"`
+		}
+
+		now := runutil.ClockFromContext(ctx).Now()
+		report := runutil.HealthReport()
+		result := make([]workerStatus, 0, len(report))
+		for _, status := range report {
+			ws := workerStatus{HealthStatus: status, Ready: true}
+			err := status.Ready(now, config.healthStaleness)
+			if err != nil {
+				ws.Ready = false
+				ws.Error = err.Error()
+			}
+			result = append(result, ws)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		err := enc.Encode(result)
+		if err != nil {
+			logutil.Get(ctx).Error("failed to encode worker health", "error", err)
+		}
+	})
+	mux.HandleFunc("/workers", func(w http.ResponseWriter, r *http.Request) {
+		nodes := runutil.WorkerGraph()
+
+		if r.URL.Query().Get("format") == "dot" {
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			fmt.Fprint(w, runutil.WorkerGraphDOT(nodes))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		err := enc.Encode(nodes)
+		if err != nil {
+			logutil.Get(ctx).Error("failed to encode worker graph", "error", err)
+		}
+	})
+
+	switch {
+	case config.adminUsername == "" && config.adminPassword == "":
+	case config.adminUsername == "" || config.adminPassword == "":
+		logutil.Get(ctx).Error("job control and log level endpoints are disabled, because the admin username or password is empty")
+	default:
+		authenticated := adminBasicAuth(config)
+		registerJobControlHandlers(ctx, mux, authenticated)
+		registerLogLevelHandlers(ctx, mux, authenticated)
+	}
 
 	// Copied from init in https://golang.org/src/net/http/pprof/pprof.go,
 	// because the package does not allow specifying a mux.
@@ -66,21 +225,158 @@ func AdminAPIListenAndServe(ctx context.Context, opts ...AdminAPIListenAndServeO
 	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
-	// The admin api gets a its own context, because we want to delay the
-	// server shutdown as long as possible. The reason for this is that Istio
-	// starts to block all outgoing connections as soon as there is no
-	// listening server anymore. Also a graceful shutdown is not needed for the
-	// admin API, so it is also not necessary to cancel the context.
-	bg := context.Background()
+	return mux
+}
 
-	go func() {
-		serverAddress := net.JoinHostPort(config.host, config.port)
+var adminJobsTemplate = template.Must(template.New("jobs").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Jobs</title></head>
+<body>
+<h1>Jobs</h1>
+<table border="1" cellpadding="4">
+<tr><th>Name</th><th>ID</th><th>State</th><th>Last Start</th><th>Last End</th><th>Last Error</th><th></th></tr>
+{{- range . }}
+<tr>
+<td>{{ .Name }}</td>
+<td>{{ .ID }}</td>
+<td>{{ if .Running }}running{{ else if .Triggered }}triggered{{ else if .Paused }}paused{{ else }}idle{{ end }}</td>
+<td>{{ if not .LastStart.IsZero }}{{ .LastStart.Format "2006-01-02 15:04:05" }}{{ end }}</td>
+<td>{{ if not .LastEnd.IsZero }}{{ .LastEnd.Format "2006-01-02 15:04:05" }}{{ end }}</td>
+<td>{{ .LastError }}</td>
+<td>
+<form method="post" action="/jobs/trigger?id={{ .ID }}" style="display:inline"><button>Trigger</button></form>
+{{- if .Paused }}
+<form method="post" action="/jobs/resume?id={{ .ID }}" style="display:inline"><button>Resume</button></form>
+{{- else }}
+<form method="post" action="/jobs/pause?id={{ .ID }}" style="display:inline"><button>Pause</button></form>
+{{- end }}
+</td>
+</tr>
+{{- else }}
+<tr><td colspan="7">no scheduled jobs running</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
 
-		logutil.Get(ctx).Debug("admin api listening", "address", serverAddress)
+// adminBasicAuth returns a middleware that protects the admin endpoints with
+// the credentials of WithAdminAuth.
+func adminBasicAuth(config adminAPIListenAndServeOptions) func(http.HandlerFunc) http.Handler {
+	return func(next http.HandlerFunc) http.Handler {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(username), []byte(config.adminUsername)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(password), []byte(config.adminPassword)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
 
-		err := ListenAndServeWithContext(bg, serverAddress, mux)
+			next(w, r)
+		})
+
+		// Browsers send the basic auth credentials automatically, therefore
+		// the forms need protection against cross-site requests.
+		return http.NewCrossOriginProtection().Handler(handler)
+	}
+}
+
+func registerJobControlHandlers(ctx context.Context, mux *http.ServeMux, authenticated func(http.HandlerFunc) http.Handler) {
+
+	mux.Handle("GET /jobs", authenticated(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := adminJobsTemplate.Execute(w, runutil.JobStatuses())
 		if err != nil {
-			logutil.Get(ctx).Error(err.Error())
+			logutil.Get(ctx).Error("failed to render jobs page", "error", err)
 		}
-	}()
+	}))
+
+	mux.Handle("GET /jobs/status", authenticated(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		err := enc.Encode(runutil.JobStatuses())
+		if err != nil {
+			logutil.Get(ctx).Error("failed to encode job status", "error", err)
+		}
+	}))
+
+	actions := map[string]func(*runutil.JobControl){
+		"trigger": (*runutil.JobControl).Trigger,
+		"pause":   (*runutil.JobControl).Pause,
+		"resume":  (*runutil.JobControl).Resume,
+	}
+
+	for action, fn := range actions {
+		mux.Handle("POST /jobs/"+action, authenticated(func(w http.ResponseWriter, r *http.Request) {
+			id := r.URL.Query().Get("id")
+			control, ok := runutil.GetJobControl(id)
+			if !ok {
+				http.Error(w, fmt.Sprintf("job %q not found", id), http.StatusNotFound)
+				return
+			}
+
+			logutil.Get(ctx).Info("job control action",
+				"action", action, "job", control.Status().Name, "job-id", id)
+			fn(control)
+
+			http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+		}))
+	}
+}
+
+func registerLogLevelHandlers(ctx context.Context, mux *http.ServeMux, authenticated func(http.HandlerFunc) http.Handler) {
+	type logLevels struct {
+		Level      slog.Level            `json:"level"`
+		Subsystems map[string]slog.Level `json:"subsystems"`
+	}
+
+	writeLevels := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		err := enc.Encode(logLevels{
+			Level:      logutil.Level(),
+			Subsystems: logutil.SubsystemLevels(),
+		})
+		if err != nil {
+			logutil.Get(ctx).Error("failed to encode log levels", "error", err)
+		}
+	}
+
+	mux.Handle("GET /log/level", authenticated(func(w http.ResponseWriter, r *http.Request) {
+		writeLevels(w)
+	}))
+
+	mux.Handle("POST /log/level", authenticated(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			subsystem = r.URL.Query().Get("subsystem")
+			value     = r.URL.Query().Get("level")
+		)
+
+		if value == "reset" && subsystem != "" {
+			logutil.Get(ctx).Info("resetting log level", "target-subsystem", subsystem)
+			logutil.ResetSubsystemLevel(subsystem)
+			writeLevels(w)
+			return
+		}
+
+		var level slog.Level
+		err := level.UnmarshalText([]byte(value))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logutil.Get(ctx).Info("changing log level", "level", level, "target-subsystem", subsystem)
+		if subsystem == "" {
+			logutil.SetLevel(level)
+		} else {
+			logutil.SetSubsystemLevel(subsystem, level)
+		}
+
+		writeLevels(w)
+	}))
 }
@@ -0,0 +1,118 @@
+package webutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/logutil"
+	"github.com/rebuy-de/rebuy-go-sdk/v10/pkg/runutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveAdmin(t *testing.T, handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestAdminHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mux := newAdminMux(ctx, adminAPIListenAndServeOptions{})
+
+	w := serveAdmin(t, mux, http.MethodGet, "/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "OK\n", w.Body.String())
+
+	cancel()
+
+	w = serveAdmin(t, mux, http.MethodGet, "/health")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "SHUTTING DOWN\n", w.Body.String())
+
+	w = serveAdmin(t, mux, http.MethodGet, "/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestAdminHealthReady(t *testing.T) {
+	mux := newAdminMux(context.Background(), adminAPIListenAndServeOptions{})
+	workerCtx := logutil.Start(context.Background(), "test-admin-ready")
+
+	runutil.HealthCheckpoint(workerCtx, errors.New("broken"))
+
+	w := serveAdmin(t, mux, http.MethodGet, "/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "worker /test-admin-ready is firing: broken")
+
+	var statuses []struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+		Ready bool   `json:"ready"`
+		Error string `json:"error"`
+	}
+	w = serveAdmin(t, mux, http.MethodGet, "/health/workers")
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "/test-admin-ready", statuses[0].Name)
+	assert.Equal(t, runutil.HealthStateFiring, statuses[0].State)
+	assert.False(t, statuses[0].Ready)
+	assert.Equal(t, "worker /test-admin-ready is firing: broken", statuses[0].Error)
+
+	runutil.HealthCheckpoint(workerCtx, nil)
+
+	w = serveAdmin(t, mux, http.MethodGet, "/health/ready")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "OK\n", w.Body.String())
+}
+
+func TestAdminAuth(t *testing.T) {
+	var config adminAPIListenAndServeOptions
+	WithAdminAuth("admin", "secret")(&config)
+	mux := newAdminMux(context.Background(), config)
+
+	for _, path := range []string{"/jobs", "/jobs/status", "/log/level"} {
+		t.Run(path, func(t *testing.T) {
+			w := serveAdmin(t, mux, http.MethodGet, path)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.SetBasicAuth("admin", "wrong")
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			r = httptest.NewRequest(http.MethodGet, path, nil)
+			r.SetBasicAuth("admin", "secret")
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestAdminAuthDisabled(t *testing.T) {
+	for _, opt := range []AdminAPIListenAndServeOption{
+		WithAdminAuth("", ""),
+		WithAdminAuth("admin", ""),
+		WithAdminAuth("", "secret"),
+	} {
+		var config adminAPIListenAndServeOptions
+		opt(&config)
+		mux := newAdminMux(context.Background(), config)
+
+		for _, path := range []string{"/jobs", "/log/level"} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.SetBasicAuth(config.adminUsername, config.adminPassword)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusNotFound, w.Code, path)
+		}
+	}
+}
@@ -100,6 +100,10 @@ func DefaultMiddlewares() Middlewares {
 	}
 }
 
+// AdminAPIOptions defines the options for the admin API that gets started by the Server. It is a separate type to
+// support dependency injection.
+type AdminAPIOptions []AdminAPIListenAndServeOption
+
 // Server is a web server targeted on projects that have a user-facing web interface. It supports dependency injection
 // using dig.
 type Server struct {
@@ -108,6 +112,7 @@ type Server struct {
 	AssetCacheDuration AssetCacheDuration
 	Handlers           []Handler
 	Middlewares        Middlewares
+	AdminAPIOptions    AdminAPIOptions
 }
 
 // ServerParams defines all parameters that are needed for the Server. Its fields can be injected using dig.
@@ -119,6 +124,7 @@ type ServerParams struct {
 	AssetCacheDuration AssetCacheDuration `optional:"true"`
 	Handlers           []Handler          `group:"handler"`
 	Middlewares        Middlewares        `optional:"true"`
+	AdminAPIOptions    AdminAPIOptions    `optional:"true"`
 }
 
 // Handler is the interface that HTTP handlers need to implement to get picked up and served by the Server.
@@ -143,6 +149,7 @@ func NewServer(p ServerParams) *Server {
 		AssetCacheDuration: p.AssetCacheDuration,
 		Handlers:           p.Handlers,
 		Middlewares:        middlewares,
+		AdminAPIOptions:    p.AdminAPIOptions,
 	}
 }
 
@@ -152,7 +159,7 @@ func (s *Server) Workers() []runutil.Worker {
 }
 
 func (s *Server) Run(ctx context.Context) error {
-	AdminAPIListenAndServe(ctx)
+	AdminAPIListenAndServe(ctx, s.AdminAPIOptions...)
 
 	// Delay the context cancel by 5s to give Kubernetes some time to redirect
 	// traffic to another pod.